@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/mitchellh/go-homedir"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheEntry is the content of a cache file, data with the time it was written.
+type cacheEntry struct {
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// cacheDir returns the directory of local caches, $HOME/.cache/qingcloud-cli.
+func cacheDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cache", "qingcloud-cli"), nil
+}
+
+// configDir returns the directory of local states such as stacks, $HOME/.qingcloud-cli.
+func configDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".qingcloud-cli"), nil
+}
+
+// readCache decodes the cache named name into out, and returns how long ago the cache was written.
+func readCache(name string, out interface{}) (time.Duration, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return 0, err
+	}
+	entry := cacheEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(entry.Data, out); err != nil {
+		return 0, err
+	}
+	return time.Since(entry.Time), nil
+}
+
+// writeCache saves data as the cache named name.
+func writeCache(name string, data interface{}) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(cacheEntry{Time: time.Now(), Data: raw})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name+".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	//write to a temporary file first, so concurrent readers never see a partial cache
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha1"
@@ -17,12 +18,17 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 )
 
 const qingCloudApiHost = "https://api.qingcloud.com/iaas/?"
 
+// qingCloudApiUrl is the url of POST requests, whose parameters are in the body.
+const qingCloudApiUrl = "https://api.qingcloud.com/iaas/"
+
+// validZoneList is only the offline fallback, zones are discovered by DescribeZones, see availableZones.
 var validZoneList = []string{"pek3", "pek3a", "sh1a", "gd2", "ap2a"}
 var validInstanceClassList = []string{"0", "1", "101", "201"}
 var validCpuNumber = []int64{1, 2, 4, 8, 6}
@@ -30,14 +36,31 @@ var validMemoryNumber = []int64{1024, 2048, 4096, 6144, 8192, 12288, 16384, 2457
 var validInstanceType = []string{"c1m1", "c1m2", "c1m4", "c2m2", "c2m4", "c2m8", "c4m4", "c4m8", "c4m16"}
 var validCpuModel = []string{"Westmere", "SandyBridge", "IvyBridge", "Haswell", "Broadwell"}
 var validUserDataType = []string{"plain", "exec", "tar"}
+var validOutputFormat = []string{"json", "table"}
 
 type QingCloudCmd interface {
 	Send() error
 	Build(cmd *cobra.Command)
 }
 
+// newCommand creates a cobra command which sends param when running.
+func newCommand(use, short string, param QingCloudCmd) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return param.Send()
+		},
+	}
+	param.Build(cmd)
+	return cmd
+}
+
 func signature(val *url.Values, secret []byte) string {
-	httpMethod := "GET"
+	return signatureWithMethod("GET", val, secret)
+}
+
+func signatureWithMethod(httpMethod string, val *url.Values, secret []byte) string {
 	httpURI := "/iaas/"
 	stringToSign := httpMethod + "\n" + httpURI + "\n" + val.Encode()
 	var mac hash.Hash
@@ -56,14 +79,7 @@ func concatQueryUrl(val *url.Values, signedStr string) string {
 }
 
 func doHttpGetRequest(urlStr string) error {
-	client := &http.Client{Timeout: time.Minute}
-	resp, err := client.Get(urlStr)
-	if err != nil {
-		fmt.Println(err)
-		return err
-	}
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
+	data, err := doHttpGetData(urlStr, time.Minute)
 	if err == nil {
 		printPrettyJson(data)
 	} else {
@@ -72,12 +88,72 @@ func doHttpGetRequest(urlStr string) error {
 	return err
 }
 
+func doHttpGetData(urlStr string, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
 func sendHttpRequest(val *url.Values, secret []byte) error {
 	signedStr := signature(val, secret)
 	urlStr := concatQueryUrl(val, signedStr)
 	return doHttpGetRequest(urlStr)
 }
 
+func sendHttpRequestData(val *url.Values, secret []byte, timeout time.Duration) ([]byte, error) {
+	signedStr := signature(val, secret)
+	urlStr := concatQueryUrl(val, signedStr)
+	return doHttpGetData(urlStr, timeout)
+}
+
+// sendHttpPostData sends the parameters in the form body instead of the url,
+// for long or secret parameters such as the private key of server certificate.
+func sendHttpPostData(val *url.Values, secret []byte, timeout time.Duration) ([]byte, error) {
+	form := url.Values{}
+	for k, v := range *val {
+		form[k] = v
+	}
+	form.Set("signature", signatureWithMethod("POST", val, secret))
+	return doHttpPostData(qingCloudApiUrl, form, timeout)
+}
+
+func doHttpPostData(urlStr string, form url.Values, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.PostForm(urlStr, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// apiResponse holds the fields shared by every api response.
+type apiResponse struct {
+	Action  string `json:"action"`
+	JobId   string `json:"job_id"`
+	RetCode int    `json:"ret_code"`
+	Message string `json:"message"`
+}
+
+// decodeResponse unmarshal data into out, a non-zero ret_code is returned as error.
+func decodeResponse(data []byte, out interface{}) error {
+	resp := apiResponse{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	if resp.RetCode != 0 {
+		return fmt.Errorf("%s failed, ret_code:%d, message:%s", resp.Action, resp.RetCode, resp.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
 func buildCobraFlags(typeOf reflect.Type, valueOfRead, valueOfWrite reflect.Value, cmd *cobra.Command) error {
 	for i := 0; i < typeOf.NumField(); i++ {
 		fieldType := typeOf.Field(i)
@@ -88,6 +164,7 @@ func buildCobraFlags(typeOf reflect.Type, valueOfRead, valueOfWrite reflect.Valu
 		required := fieldType.Tag.Get("required")
 		defaultVal := fieldType.Tag.Get("default")
 		usage := fieldType.Tag.Get("usage")
+		shorthand := fieldType.Tag.Get("shorthand")
 
 		v := valueOfWrite.Elem().FieldByName(fieldType.Name)
 		p := unsafe.Pointer(v.UnsafeAddr())
@@ -95,22 +172,26 @@ func buildCobraFlags(typeOf reflect.Type, valueOfRead, valueOfWrite reflect.Valu
 		switch valueType := valueOfRead.Field(i).Interface().(type) {
 		case string:
 			pStr := (*string)(p)
-			cmd.Flags().StringVarP(pStr, name, "", defaultVal, usage)
+			cmd.Flags().StringVarP(pStr, name, shorthand, defaultVal, usage)
 		case int64:
 			pInt64 := (*int64)(p)
 			defaultInt64Val, _ := strconv.Atoi(defaultVal)
-			cmd.Flags().Int64VarP(pInt64, name, "", int64(defaultInt64Val), usage)
+			cmd.Flags().Int64VarP(pInt64, name, shorthand, int64(defaultInt64Val), usage)
 		case bool:
 			pBool := (*bool)(p)
 			defaultBoolVal := false
 			if defaultVal == "true" {
 				defaultBoolVal = true
 			}
-			cmd.Flags().BoolVarP(pBool, name, "", defaultBoolVal, usage)
+			cmd.Flags().BoolVarP(pBool, name, shorthand, defaultBoolVal, usage)
 		case []string:
 			pStrArray := (*[]string)(p)
-			cmd.Flags().StringArrayVarP(pStrArray, name, "", make([]string, 0), usage)
+			cmd.Flags().StringArrayVarP(pStrArray, name, shorthand, make([]string, 0), usage)
 		default:
+			//nested list parameter is filled by the command itself, not by flag
+			if isStructSlice(v) {
+				continue
+			}
 			return errors.New(fmt.Sprintf("unsupport type, name:%s, type:%T", name, valueType))
 		}
 		if required == "1" {
@@ -124,7 +205,7 @@ func buildUrlValues(typeOf reflect.Type, valueOfRead, valueOfWrite reflect.Value
 	for i := 0; i < typeOf.NumField(); i++ {
 		fieldType := typeOf.Field(i)
 		name := fieldType.Tag.Get("name")
-		if len(name) == 0 {
+		if len(name) == 0 || fieldType.Tag.Get("local") == "1" {
 			continue
 		}
 		v := valueOfWrite.Elem().FieldByName(fieldType.Name)
@@ -149,12 +230,99 @@ func buildUrlValues(typeOf reflect.Type, valueOfRead, valueOfWrite reflect.Value
 			}
 
 		default:
+			if isStructSlice(v) {
+				if err := buildNestedUrlValues(name, v, val); err != nil {
+					return err
+				}
+				continue
+			}
 			return errors.New(fmt.Sprintf("unsupport type, name:%s, type:%T", name, valueType))
 		}
 	}
 	return nil
 }
 
+func isStructSlice(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct
+}
+
+// setFieldsByName fills the fields of param, a pointer to a command struct, as its flags do: every field
+// gets the default value of its tag, then the value in values keyed by the name tag. Unknown names are errors.
+func setFieldsByName(param interface{}, values map[string][]string) error {
+	v := reflect.ValueOf(param).Elem()
+	typeOf := v.Type()
+	known := make(map[string]bool)
+	for i := 0; i < typeOf.NumField(); i++ {
+		fieldType := typeOf.Field(i)
+		name := fieldType.Tag.Get("name")
+		if len(name) == 0 || isStructSlice(v.Field(i)) {
+			continue
+		}
+		known[name] = true
+		value, ok := values[name]
+		if !ok {
+			defaultVal := fieldType.Tag.Get("default")
+			if len(defaultVal) == 0 {
+				continue
+			}
+			value = []string{defaultVal}
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid %s, %v", name, err)
+		}
+	}
+	for name := range values {
+		if !known[name] {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+	return nil
+}
+
+func setField(v reflect.Value, value []string) error {
+	if v.Kind() != reflect.Slice && len(value) != 1 {
+		return fmt.Errorf("expected one value, got %d", len(value))
+	}
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(value[0])
+	case int64:
+		n, err := strconv.ParseInt(value[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case bool:
+		b, err := strconv.ParseBool(value[0])
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case []string:
+		v.Set(reflect.ValueOf(append([]string(nil), value...)))
+	default:
+		return fmt.Errorf("unsupport type %s", v.Type())
+	}
+	return nil
+}
+
+// buildNestedUrlValues encodes a slice of struct as nested list parameter, such as rules.1.protocol.
+func buildNestedUrlValues(name string, v reflect.Value, val *url.Values) error {
+	for i := 0; i != v.Len(); i++ {
+		elem := v.Index(i)
+		elemVal := &url.Values{}
+		if err := buildUrlValues(elem.Type(), elem, elem.Addr(), elemVal); err != nil {
+			return err
+		}
+		for key, list := range *elemVal {
+			for _, item := range list {
+				val.Add(fmt.Sprintf("%s.%d.%s", name, i+1, key), item)
+			}
+		}
+	}
+	return nil
+}
+
 func printPrettyJson(in []byte) {
 	var out bytes.Buffer
 	if err := json.Indent(&out, in, "", "    "); err == nil {
@@ -182,6 +350,40 @@ func validInt64Param(list []int64, param int64) bool {
 	return false
 }
 
+// parseDuration is time.ParseDuration which also accepts the day and week unit, such as 30d and 2w.
+// Only positive durations are valid, a zero or negative age or interval is always a mistake.
+func parseDuration(s string) (time.Duration, error) {
+	d, err := parseAnyDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid duration %s, must be positive", s)
+	}
+	return d, nil
+}
+
+func parseAnyDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %s", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// confirm prints prompt and returns true only if the user answers y or yes.
+func confirm(prompt string) bool {
+	fmt.Print(prompt, " [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func mustBeOk(err error) {
 	if err != nil {
 		fmt.Println(err)
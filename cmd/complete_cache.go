@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"path"
+	"time"
+)
+
+const completionCacheTTL = time.Minute
+const completionTimeout = 3 * time.Second
+
+// completionInstanceCmd returns the instanceCmd of action used by completion, with a short timeout
+// and without zone check, since completion must never exit the process.
+func completionInstanceCmd(action string) instanceCmd {
+	return instanceCmd{
+		action:        action,
+		timeout:       completionTimeout,
+		skipZoneCheck: true,
+	}
+}
+
+// completionProfile loads the config file given by a --config flag typed before completion,
+// then returns the profile of current credentials and the zone in use.
+func completionProfile() (string, string, error) {
+	//initConfig ran before the flags of completed command were parsed
+	if cfgFile != "" && viper.ConfigFileUsed() != cfgFile {
+		viper.SetConfigFile(cfgFile)
+		viper.ReadInConfig()
+	}
+
+	accessKeyId := viper.GetString("qy_access_key_id")
+	if len(accessKeyId) == 0 || len(viper.GetString("qy_secret_access_key")) == 0 {
+		return "", "", errors.New("no credentials to complete")
+	}
+	z := zone
+	if len(z) == 0 {
+		z = viper.GetString("zone")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(accessKeyId)))[:12], z, nil
+}
+
+// completionCacheName returns the cache name of resource completions for profile and zone.
+func completionCacheName(profile, zone, resource string) string {
+	return path.Join("completion", profile, zone, resource)
+}
+
+// cachedCompletions returns the completions of resource, which are fetched by fetch and cached locally
+// for completionCacheTTL. If fetch fails, such as offline, the stale cache is used.
+func cachedCompletions(resource string, fetch func() ([]string, error)) []string {
+	profile, z, err := completionProfile()
+	if err != nil {
+		return nil
+	}
+	name := completionCacheName(profile, z, resource)
+
+	var cached []string
+	age, err := readCache(name, &cached)
+	if err == nil && age < completionCacheTTL {
+		return cached
+	}
+	fetched, err := fetch()
+	if err != nil {
+		return cached
+	}
+	writeCache(name, fetched)
+	return fetched
+}
+
+// registerCachedCompletion completes flagName with the completions of resource, see cachedCompletions.
+func registerCachedCompletion(cmd *cobra.Command, flagName, resource string, fetch func() ([]string, error)) {
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return cachedCompletions(resource, fetch), cobra.ShellCompDirectiveDefault
+	})
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCachedCompletions(t *testing.T) {
+	home, err := ioutil.TempDir("", "qingcloud-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	viper.Set("qy_access_key_id", "")
+	if got := cachedCompletions("instances", func() ([]string, error) {
+		t.Fatal("fetch without credentials")
+		return nil, nil
+	}); len(got) != 0 {
+		t.Fatal("completions without credentials, got=", got)
+	}
+
+	viper.Set("qy_access_key_id", "test-id")
+	viper.Set("qy_secret_access_key", "test-secret")
+	viper.Set("zone", "pek3")
+	defer viper.Set("qy_access_key_id", "")
+	defer viper.Set("qy_secret_access_key", "")
+	defer viper.Set("zone", "")
+
+	fetched := []string{"i-1\tweb (running)"}
+	got := cachedCompletions("instances", func() ([]string, error) { return fetched, nil })
+	if len(got) != 1 || got[0] != fetched[0] {
+		t.Fatal("fetched completions, got=", got)
+	}
+
+	//fresh cache is used without fetching
+	got = cachedCompletions("instances", func() ([]string, error) {
+		t.Fatal("fetch with fresh cache")
+		return nil, nil
+	})
+	if len(got) != 1 || got[0] != fetched[0] {
+		t.Fatal("cached completions, got=", got)
+	}
+
+	//stale cache is used when fetch fails
+	profile, z, _ := completionProfile()
+	raw, _ := json.Marshal(fetched)
+	content, _ := json.Marshal(cacheEntry{Time: time.Now().Add(-time.Hour), Data: raw})
+	path := filepath.Join(home, ".cache", "qingcloud-cli", completionCacheName(profile, z, "instances")+".json")
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	got = cachedCompletions("instances", func() ([]string, error) { return nil, errors.New("offline") })
+	if len(got) != 1 || got[0] != fetched[0] {
+		t.Fatal("stale completions, got=", got)
+	}
+}
@@ -10,6 +10,6 @@ var echoDemoCmd = &cobra.Command{
 	Short: "echo demo configuration to standard output",
 	Long: "qingcloud-cli echo-demo-config > $HOME/.qingcloud.yaml",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("qy_access_key_id: 'QYACCESSKEYIDEXAMPLE'\nqy_secret_access_key: 'SECRETACCESSKEY'\nzone: 'pek3'\n\n")
+		fmt.Print("qy_access_key_id: 'QYACCESSKEYIDEXAMPLE'\nqy_secret_access_key: 'SECRETACCESSKEY'\nzone: 'pek3'\n# zones of private cloud which DescribeZones does not return\n# extra_zones: ['zone1', 'zone2']\n# private key files of keypairs, used by ssh\n# ssh_keys:\n#   kp-xxxxxxxx: '~/.ssh/id_rsa'\n# ids of the same image, vxnet, security group and keypair in other zones, used by run-instances --zones\n# zone_ids:\n#   pek3b:\n#     img-xxxxxxxx: 'img-yyyyyyyy'\n\n\n")
 	},
 }
\ No newline at end of file
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+)
+
+var validEipBillingMode = []string{"bandwidth", "traffic"}
+var validEipStatus = []string{"pending", "available", "associated", "suspended", "released", "ceased"}
+
+func addEipCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "eips",
+		Short: "Manage elastic ips, describe, allocate, associate, dissociate, release eip and change bandwidth",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch eip list, filter by eip id, instance, status etc.",
+		&describeEipCmd{instanceCmd: instanceCmd{action: "DescribeEips"}}))
+	cmd.AddCommand(newCommand("allocate", "Allocate one or many eips with the same configuration",
+		&allocateEipCmd{instanceCmd: instanceCmd{action: "AllocateEips"}}))
+	cmd.AddCommand(newCommand("associate", "Associate an available eip to an instance",
+		&associateEipCmd{instanceCmd: instanceCmd{action: "AssociateEip"}}))
+	cmd.AddCommand(newCommand("dissociate", "Dissociate one or many eips from their instances",
+		&dissociateEipCmd{instanceCmd: instanceCmd{action: "DissociateEips"}}))
+	cmd.AddCommand(newCommand("release", "Release one or many eips which given eip id",
+		&releaseEipCmd{instanceCmd: instanceCmd{action: "ReleaseEips"}}))
+	cmd.AddCommand(newCommand("change-bandwidth", "Change the bandwidth of one or many eips",
+		&changeEipBandwidthCmd{instanceCmd: instanceCmd{action: "ChangeEipsBandwidth"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeEipCmd)(nil)
+var _ QingCloudCmd = (*allocateEipCmd)(nil)
+var _ QingCloudCmd = (*associateEipCmd)(nil)
+var _ QingCloudCmd = (*dissociateEipCmd)(nil)
+var _ QingCloudCmd = (*releaseEipCmd)(nil)
+var _ QingCloudCmd = (*changeEipBandwidthCmd)(nil)
+
+// eipItem is one element of eip_set in DescribeEips response.
+type eipItem struct {
+	EipId       string    `json:"eip_id"`
+	EipName     string    `json:"eip_name"`
+	EipAddr     string    `json:"eip_addr"`
+	Bandwidth   int64     `json:"bandwidth"`
+	BillingMode string    `json:"billing_mode"`
+	Status      string    `json:"status"`
+	Tags        []tagItem `json:"tags"`
+	Resource    struct {
+		ResourceId   string `json:"resource_id"`
+		ResourceName string `json:"resource_name"`
+		ResourceType string `json:"resource_type"`
+	} `json:"resource"`
+}
+
+func describeEips(param *describeEipCmd) ([]eipItem, error) {
+	type response struct {
+		EipSet []eipItem `json:"eip_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.EipSet, nil
+}
+
+// describeAllEips fetches all eips matched param page by page.
+func describeAllEips(param *describeEipCmd) ([]eipItem, error) {
+	type response struct {
+		EipSet     []eipItem `json:"eip_set"`
+		TotalCount int64     `json:"total_count"`
+	}
+
+	var items []eipItem
+	for {
+		param.Offset = int64(len(items))
+		param.Limit = 100
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		items = append(items, resp.EipSet...)
+		if len(resp.EipSet) == 0 || int64(len(items)) >= resp.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+// registerEipIdCompletion completes flagName with the eip ids in status, described by address and the attached resource.
+func registerEipIdCompletion(cmd *cobra.Command, flagName string, status ...string) {
+	resource := path.Join("eips", strings.Join(status, ","))
+	registerCachedCompletion(cmd, flagName, resource, func() ([]string, error) {
+		var tmp []string
+		items, err := describeEips(&describeEipCmd{
+			instanceCmd: completionInstanceCmd("DescribeEips"),
+			Status:      status,
+			Limit:       100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range items {
+			if len(v.Resource.ResourceId) != 0 {
+				tmp = append(tmp, fmt.Sprintf("%s\t%s -> %s (%s)", v.EipId, v.EipAddr, v.Resource.ResourceId, v.Resource.ResourceName))
+			} else {
+				tmp = append(tmp, fmt.Sprintf("%s\t%s %s (%s)", v.EipId, v.EipAddr, v.EipName, v.Status))
+			}
+		}
+		return tmp, nil
+	})
+}
+
+type describeEipCmd struct {
+	instanceCmd
+	EipIds     []string `name:"eips" usage:"eip id[s] which want to fetch. Multiple eips set like --eips eip1 --eips eip2"`
+	InstanceId string   `name:"instance_id" usage:"filter by the instance which eip associated to"`
+	Status     []string `name:"status" usage:"eip status[es] which want to fetch. Multiple status --status st1 --status st2"`
+	SearchWord string   `name:"search_word" usage:"search keyword, eip id, name, address are supported"`
+	Tags       []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Verbose    bool     `name:"verbose" default:"false" usage:"show detail information or not"`
+	Offset     int64    `name:"offset" default:"0" usage:"matched eip offset"`
+	Limit      int64    `name:"limit" default:"20" usage:"matched eip limit, default is 20, max is 100"`
+}
+
+func (dec *describeEipCmd) Send() error {
+	if dec.Limit < 20 || dec.Limit > 100 {
+		dec.Limit = 20
+	}
+	return dec.send(dec)
+}
+
+func (dec *describeEipCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dec), reflect.ValueOf(*dec), reflect.ValueOf(dec), cmd))
+
+	//for completion
+	registerEipIdCompletion(cmd, "eips")
+	registerInstanceIdCompletion(cmd, "instance_id")
+
+	flagName := "status"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validEipStatus, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type allocateEipCmd struct {
+	instanceCmd
+	Bandwidth   int64  `name:"bandwidth" required:"1" usage:"the max bandwidth, unit Mbps"`
+	BillingMode string `name:"billing_mode" default:"bandwidth" usage:"billing mode, bandwidth or traffic"`
+	EipName     string `name:"eip_name" usage:"the eip name"`
+	Count       int64  `name:"count" usage:"the count of eip you want to allocate with the same configuration"`
+	NeedIcp     int64  `name:"need_icp" usage:"1: need ICP filing, 0: no need"`
+}
+
+func (aec *allocateEipCmd) Send() error {
+	if aec.Bandwidth <= 0 {
+		fmt.Println("bandwidth must be greater than 0")
+		os.Exit(0)
+	}
+	if !validParam(validEipBillingMode, aec.BillingMode) {
+		fmt.Println("billing mode is invalid, must be one of", validEipBillingMode)
+		os.Exit(0)
+	}
+	if aec.Count < 1 {
+		aec.Count = 1
+	}
+	return aec.send(aec)
+}
+
+func (aec *allocateEipCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*aec), reflect.ValueOf(*aec), reflect.ValueOf(aec), cmd))
+
+	//for completion
+	flagName := "billing_mode"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validEipBillingMode, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type associateEipCmd struct {
+	instanceCmd
+	EipId      string `name:"eip" required:"1" usage:"the eip id which want to associate"`
+	InstanceId string `name:"instance" required:"1" usage:"the instance id which eip associate to"`
+}
+
+func (aec *associateEipCmd) Send() error {
+	return aec.send(aec)
+}
+
+func (aec *associateEipCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*aec), reflect.ValueOf(*aec), reflect.ValueOf(aec), cmd))
+
+	//for completion
+	registerEipIdCompletion(cmd, "eip", "available")
+	registerInstanceIdCompletion(cmd, "instance")
+}
+
+type dissociateEipCmd struct {
+	instanceCmd
+	EipIds []string `name:"eips" required:"1" usage:"eip id[s] which want to dissociate. Multiple eips, --eips eip1 --eips eip2"`
+}
+
+func (dec *dissociateEipCmd) Send() error {
+	return dec.send(dec)
+}
+
+func (dec *dissociateEipCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dec), reflect.ValueOf(*dec), reflect.ValueOf(dec), cmd))
+
+	//for completion
+	registerEipIdCompletion(cmd, "eips", "associated")
+}
+
+type releaseEipCmd struct {
+	instanceCmd
+	EipIds []string `name:"eips" required:"1" usage:"eip id[s] which want to release. Multiple eips, --eips eip1 --eips eip2"`
+}
+
+func (rec *releaseEipCmd) Send() error {
+	return rec.send(rec)
+}
+
+func (rec *releaseEipCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*rec), reflect.ValueOf(*rec), reflect.ValueOf(rec), cmd))
+
+	//for completion
+	registerEipIdCompletion(cmd, "eips")
+}
+
+type changeEipBandwidthCmd struct {
+	instanceCmd
+	EipIds    []string `name:"eips" required:"1" usage:"eip id[s] which want to change. Multiple eips, --eips eip1 --eips eip2"`
+	Bandwidth int64    `name:"bandwidth" required:"1" usage:"the new max bandwidth, unit Mbps"`
+}
+
+func (cec *changeEipBandwidthCmd) Send() error {
+	if cec.Bandwidth <= 0 {
+		fmt.Println("bandwidth must be greater than 0")
+		os.Exit(0)
+	}
+	return cec.send(cec)
+}
+
+func (cec *changeEipBandwidthCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*cec), reflect.ValueOf(*cec), reflect.ValueOf(cec), cmd))
+
+	//for completion
+	registerEipIdCompletion(cmd, "eips")
+}
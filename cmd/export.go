@@ -0,0 +1,438 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var validExportFormat = []string{"yaml", "hcl"}
+
+// hclInvalidChars are the characters not allowed in terraform resource names.
+var hclInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+func addExportCmd(root *cobra.Command) {
+	root.AddCommand(newCommand("export", "Export instances and their volumes, eips and security groups as a manifest or terraform resources",
+		&exportCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+}
+
+var _ QingCloudCmd = (*exportCmd)(nil)
+
+// exportedResources is the instances and the resources attached to them.
+type exportedResources struct {
+	tag            string
+	instances      []instanceItem
+	volumes        []volumeItem
+	eips           []eipItem
+	securityGroups []securityGroupItem
+	rules          map[string][]securityGroupRuleItem
+}
+
+// selectorTag returns the tag name if selector is exactly one tag=xxx, the value may be a tag id.
+// The manifest of a tag manages every resource of the tag, so a selector which selects only a part of them,
+// such as tag=prod,name=web, exports no tag, otherwise apply would delete the other resources of the tag.
+func selectorTag(sel selector, items []instanceItem) string {
+	if len(sel) != 1 || sel[0].key != "tag" {
+		return ""
+	}
+	for _, v := range items {
+		for _, t := range v.Tags {
+			if t.TagId == sel[0].value || t.TagName == sel[0].value {
+				return t.TagName
+			}
+		}
+	}
+	return ""
+}
+
+// untagged returns true and a warning if the manifest is managed by tag but the resource does not have it,
+// such a resource is not exported, otherwise apply would create another one.
+func untagged(tag string, tags []tagItem, kind, id string, warnings *[]string) bool {
+	if hasTag(tags, tag) {
+		return false
+	}
+	*warnings = append(*warnings, fmt.Sprintf("%s %s has no tag %s, not exported, attach the tag to manage it by the manifest", kind, id, tag))
+	return true
+}
+
+// fetchExported fetches the matched instances, then the volumes, eips and security groups attached to them.
+func fetchExported(selectorStr string) (exportedResources, error) {
+	r := exportedResources{rules: make(map[string][]securityGroupRuleItem)}
+	sel, err := parseSelector(selectorStr)
+	if err != nil {
+		return r, err
+	}
+	if r.instances, err = inventoryInstances("", selectorStr); err != nil {
+		return r, err
+	}
+	r.tag = selectorTag(sel, r.instances)
+
+	var volumeIds, eipIds, securityGroupIds []string
+	seen := make(map[string]bool)
+	for _, v := range r.instances {
+		for _, id := range v.VolumeIds {
+			if !seen[id] {
+				seen[id] = true
+				volumeIds = append(volumeIds, id)
+			}
+		}
+		if id := v.Eip.EipId; len(id) != 0 && !seen[id] {
+			seen[id] = true
+			eipIds = append(eipIds, id)
+		}
+		if id := v.SecurityGroup.SecurityGroupId; len(id) != 0 && !seen[id] {
+			seen[id] = true
+			securityGroupIds = append(securityGroupIds, id)
+		}
+	}
+
+	//describe accepts at most 100 ids a request
+	for i := 0; i < len(volumeIds); i += 100 {
+		items, err := describeVolumes(&describeVolumeCmd{
+			instanceCmd: instanceCmd{action: "DescribeVolumes"},
+			VolumeIds:   volumeIds[i:minInt(i+100, len(volumeIds))],
+			Limit:       100,
+		})
+		if err != nil {
+			return r, err
+		}
+		r.volumes = append(r.volumes, items...)
+	}
+	for i := 0; i < len(eipIds); i += 100 {
+		items, err := describeEips(&describeEipCmd{
+			instanceCmd: instanceCmd{action: "DescribeEips"},
+			EipIds:      eipIds[i:minInt(i+100, len(eipIds))],
+			Limit:       100,
+		})
+		if err != nil {
+			return r, err
+		}
+		r.eips = append(r.eips, items...)
+	}
+	for i := 0; i < len(securityGroupIds); i += 100 {
+		items, err := describeSecurityGroups(&describeSecurityGroupCmd{
+			instanceCmd:      instanceCmd{action: "DescribeSecurityGroups"},
+			SecurityGroupIds: securityGroupIds[i:minInt(i+100, len(securityGroupIds))],
+			Limit:            100,
+		})
+		if err != nil {
+			return r, err
+		}
+		r.securityGroups = append(r.securityGroups, items...)
+	}
+	for _, v := range r.securityGroups {
+		if v.IsDefault != 0 {
+			continue
+		}
+		if r.rules[v.SecurityGroupId], err = describeAllSecurityGroupRules(v.SecurityGroupId); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// uniqueName returns name, or id with a warning if name is empty or used by another resource of kind,
+// because resources of manifest are identified by name.
+func uniqueName(used map[string]bool, kind, name, id string, warnings *[]string) string {
+	if len(name) == 0 || used[kind+"/"+name] {
+		*warnings = append(*warnings, fmt.Sprintf("%s %s has no unique name, exported as %s, rename it before apply", kind, id, id))
+		name = id
+	}
+	used[kind+"/"+name] = true
+	return name
+}
+
+// exportManifest converts r to a manifest. Instances of the same name are exported as one entry with count,
+// the returned warnings tell what can not be exported exactly.
+func exportManifest(zone string, r exportedResources) (manifest, []string) {
+	m := manifest{Zone: zone, Tag: r.tag}
+	var warnings []string
+	used := make(map[string]bool)
+
+	index := make(map[string]int)
+	for _, v := range r.instances {
+		name := v.InstanceName
+		if i, ok := index[name]; ok && len(name) != 0 {
+			first := &m.Instances[i]
+			if first.ImageId != v.Image.ImageId || first.CPU != v.VcpusCurrent || first.Memory != v.MemoryCurrent {
+				warnings = append(warnings, fmt.Sprintf("instance %s differs from the other instances named %s, exported as them", v.InstanceId, name))
+			}
+			first.Count++
+			continue
+		}
+		name = uniqueName(used, "instance", name, v.InstanceId, &warnings)
+		index[name] = len(m.Instances)
+
+		item := manifestInstance{
+			Name:          name,
+			Count:         1,
+			ImageId:       v.Image.ImageId,
+			CPU:           v.VcpusCurrent,
+			Memory:        v.MemoryCurrent,
+			SecurityGroup: v.SecurityGroup.SecurityGroupId,
+		}
+		for _, vxnet := range v.Vxnets {
+			item.Vxnets = append(item.Vxnets, vxnet.VxnetId)
+		}
+		if len(v.KeyPairIds) != 0 {
+			item.LoginMode, item.LoginKeyPair = "keypair", v.KeyPairIds[0]
+		}
+		m.Instances = append(m.Instances, item)
+	}
+
+	for _, v := range r.volumes {
+		if untagged(m.Tag, v.Tags, "volume", v.VolumeId, &warnings) {
+			continue
+		}
+		m.Volumes = append(m.Volumes, manifestVolume{
+			Name:       uniqueName(used, "volume", v.VolumeName, v.VolumeId, &warnings),
+			Size:       v.Size,
+			VolumeType: strconv.FormatInt(v.VolumeType, 10),
+		})
+	}
+	for _, v := range r.eips {
+		if untagged(m.Tag, v.Tags, "eip", v.EipId, &warnings) {
+			continue
+		}
+		m.Eips = append(m.Eips, manifestEip{
+			Name:        uniqueName(used, "eip", v.EipName, v.EipId, &warnings),
+			Bandwidth:   v.Bandwidth,
+			BillingMode: v.BillingMode,
+		})
+	}
+	for _, v := range r.securityGroups {
+		//the default security group can not be created or deleted, instances refer to it by id
+		if v.IsDefault != 0 || untagged(m.Tag, v.Tags, "security_group", v.SecurityGroupId, &warnings) {
+			continue
+		}
+		sg := manifestSecurityGroup{Name: uniqueName(used, "security_group", v.SecurityGroupName, v.SecurityGroupId, &warnings)}
+		for _, rule := range r.rules[v.SecurityGroupId] {
+			sg.Rules = append(sg.Rules, rule.rule())
+		}
+		m.SecurityGroups = append(m.SecurityGroups, sg)
+	}
+	return m, warnings
+}
+
+// hclBlock is a terraform resource, attrs are rendered values in order.
+type hclBlock struct {
+	kind  string
+	name  string
+	id    string
+	attrs [][2]string
+}
+
+func (b *hclBlock) set(key, value string) {
+	b.attrs = append(b.attrs, [2]string{key, value})
+}
+
+// render returns the block with the equal signs aligned, as terraform fmt does.
+func (b hclBlock) render() string {
+	width := 0
+	for _, kv := range b.attrs {
+		if len(kv[0]) > width {
+			width = len(kv[0])
+		}
+	}
+	var s strings.Builder
+	fmt.Fprintf(&s, "resource %q %q {\n", b.kind, b.name)
+	for _, kv := range b.attrs {
+		fmt.Fprintf(&s, "  %-*s = %s\n", width, kv[0], kv[1])
+	}
+	s.WriteString("}\n")
+	return s.String()
+}
+
+// hclString quotes s as a terraform string, interpolation sequences are escaped.
+func hclString(s string) string {
+	s = strconv.Quote(s)
+	return strings.NewReplacer("${", "$${", "%{", "%%{").Replace(s)
+}
+
+func hclList(values []string) string {
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// hclName returns a unique terraform resource name of kind from the resource name, or from id if name is empty.
+func hclName(used map[string]bool, kind, name, id string) string {
+	s := strings.ToLower(hclInvalidChars.ReplaceAllString(name, "_"))
+	if len(strings.Trim(s, "_")) == 0 {
+		s = hclInvalidChars.ReplaceAllString(id, "_")
+	}
+	if c := s[0]; !(c >= 'a' && c <= 'z' || c == '_') {
+		s = "_" + s
+	}
+	unique := s
+	for i := 2; used[kind+"."+unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", s, i)
+	}
+	used[kind+"."+unique] = true
+	return unique
+}
+
+// exportHcl converts r to terraform resources of the qingcloud provider, followed by the import commands.
+// Attached resources are referred by the resource addresses, so terraform knows the dependencies.
+func exportHcl(r exportedResources) string {
+	used := make(map[string]bool)
+	refs := make(map[string]string)
+	var blocks []hclBlock
+
+	for _, v := range r.securityGroups {
+		//the default security group is not managed, instances refer to it by id
+		if v.IsDefault != 0 {
+			continue
+		}
+		b := hclBlock{kind: "qingcloud_security_group", id: v.SecurityGroupId}
+		b.name = hclName(used, b.kind, v.SecurityGroupName, v.SecurityGroupId)
+		b.set("name", hclString(v.SecurityGroupName))
+		refs[v.SecurityGroupId] = b.kind + "." + b.name + ".id"
+		blocks = append(blocks, b)
+	}
+	for _, v := range r.eips {
+		b := hclBlock{kind: "qingcloud_eip", id: v.EipId}
+		b.name = hclName(used, b.kind, v.EipName, v.EipId)
+		b.set("name", hclString(v.EipName))
+		b.set("bandwidth", strconv.FormatInt(v.Bandwidth, 10))
+		if len(v.BillingMode) != 0 {
+			b.set("billing_mode", hclString(v.BillingMode))
+		}
+		refs[v.EipId] = b.kind + "." + b.name + ".id"
+		blocks = append(blocks, b)
+	}
+	for _, v := range r.volumes {
+		b := hclBlock{kind: "qingcloud_volume", id: v.VolumeId}
+		b.name = hclName(used, b.kind, v.VolumeName, v.VolumeId)
+		b.set("name", hclString(v.VolumeName))
+		b.set("size", strconv.FormatInt(v.Size, 10))
+		b.set("type", strconv.FormatInt(v.VolumeType, 10))
+		refs[v.VolumeId] = b.kind + "." + b.name + ".id"
+		blocks = append(blocks, b)
+	}
+
+	ref := func(id string) string {
+		if addr, ok := refs[id]; ok {
+			return addr
+		}
+		return hclString(id)
+	}
+	for _, v := range r.instances {
+		b := hclBlock{kind: "qingcloud_instance", id: v.InstanceId}
+		b.name = hclName(used, b.kind, v.InstanceName, v.InstanceId)
+		b.set("name", hclString(v.InstanceName))
+		b.set("image_id", hclString(v.Image.ImageId))
+		b.set("cpu", strconv.FormatInt(v.VcpusCurrent, 10))
+		b.set("memory", strconv.FormatInt(v.MemoryCurrent, 10))
+		b.set("instance_class", strconv.FormatInt(v.InstanceClass, 10))
+		if len(v.Vxnets) != 0 {
+			b.set("managed_vxnet_id", hclString(v.Vxnets[0].VxnetId))
+			if len(v.Vxnets[0].PrivateIp) != 0 {
+				b.set("private_ip", hclString(v.Vxnets[0].PrivateIp))
+			}
+		}
+		if len(v.KeyPairIds) != 0 {
+			var ids []string
+			for _, id := range v.KeyPairIds {
+				ids = append(ids, hclString(id))
+			}
+			b.set("keypair_ids", hclList(ids))
+		}
+		if len(v.SecurityGroup.SecurityGroupId) != 0 {
+			b.set("security_group_id", ref(v.SecurityGroup.SecurityGroupId))
+		}
+		if len(v.Eip.EipId) != 0 {
+			b.set("eip_id", ref(v.Eip.EipId))
+		}
+		if len(v.VolumeIds) != 0 {
+			var ids []string
+			for _, id := range v.VolumeIds {
+				ids = append(ids, ref(id))
+			}
+			b.set("volume_ids", hclList(ids))
+		}
+		if len(v.Tags) != 0 {
+			var ids []string
+			for _, t := range v.Tags {
+				ids = append(ids, hclString(t.TagId))
+			}
+			b.set("tag_ids", hclList(ids))
+		}
+		blocks = append(blocks, b)
+	}
+
+	var s strings.Builder
+	for i, b := range blocks {
+		if i != 0 {
+			s.WriteString("\n")
+		}
+		s.WriteString(b.render())
+	}
+	if len(blocks) != 0 {
+		s.WriteString("\n# import the existing resources before terraform plan:\n")
+		for _, b := range blocks {
+			fmt.Fprintf(&s, "# terraform import %s.%s %s\n", b.kind, b.name, b.id)
+		}
+	}
+	return s.String()
+}
+
+type exportCmd struct {
+	instanceCmd
+	Selector string `name:"selector" local:"1" usage:"only export matched instances, such as tag=prod. Keys are tag, name, status, type, vxnet"`
+	Format   string `name:"format" local:"1" default:"yaml" usage:"output format, yaml is the manifest of apply, hcl is the terraform resources with import commands"`
+	File     string `name:"file" local:"1" usage:"the file to write. Default, print the result"`
+}
+
+func (ec *exportCmd) Send() error {
+	if !validParam(validExportFormat, ec.Format) {
+		fmt.Println("format is invalid, must be one of", validExportFormat)
+		os.Exit(0)
+	}
+	ec.commonParam()
+	r, err := fetchExported(ec.Selector)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if ec.Format == "hcl" {
+		data = []byte(exportHcl(r))
+	} else {
+		m, warnings := exportManifest(ec.zone, r)
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "warning:", w)
+		}
+		if data, err = yaml.Marshal(m); err != nil {
+			return err
+		}
+	}
+
+	if len(ec.File) == 0 {
+		fmt.Print(string(data))
+		return nil
+	}
+	path, err := homedir.Expand(ec.File)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Println(len(r.instances), "instance(s) exported to", path)
+	return nil
+}
+
+func (ec *exportCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ec), reflect.ValueOf(*ec), reflect.ValueOf(ec), cmd))
+
+	//for completion
+	flagName := "format"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validExportFormat, cobra.ShellCompDirectiveDefault
+	})
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func exportedFixture() exportedResources {
+	web := instanceItem{InstanceId: "i-1", InstanceName: "web", VcpusCurrent: 2, MemoryCurrent: 4096,
+		KeyPairIds: []string{"kp-1"}, Tags: []tagItem{{TagId: "tag-1", TagName: "prod"}}, VolumeIds: []string{"vol-1"}}
+	web.Image.ImageId = "img-1"
+	web.Eip.EipId = "eip-1"
+	web.SecurityGroup.SecurityGroupId = "sg-1"
+	web.Vxnets = []instanceVxnet{{VxnetId: "vxnet-1", PrivateIp: "192.168.0.2"}}
+	web2 := web
+	web2.InstanceId, web2.Eip.EipId, web2.VolumeIds = "i-2", "", nil
+	prod := []tagItem{{TagId: "tag-1", TagName: "prod"}}
+	db := instanceItem{InstanceId: "i-3", InstanceName: "db", VcpusCurrent: 4, MemoryCurrent: 8192, Tags: prod}
+	db.Image.ImageId = "img-1"
+	db.SecurityGroup.SecurityGroupId = "sg-0"
+
+	return exportedResources{
+		tag:            "prod",
+		instances:      []instanceItem{web, web2, db},
+		volumes:        []volumeItem{{VolumeId: "vol-1", VolumeName: "", Size: 100, VolumeType: 2, Tags: prod}},
+		eips:           []eipItem{{EipId: "eip-1", EipName: "web", Bandwidth: 5, BillingMode: "traffic", Tags: prod}},
+		securityGroups: []securityGroupItem{{SecurityGroupId: "sg-1", SecurityGroupName: "web", Tags: prod}, {SecurityGroupId: "sg-0", IsDefault: 1}},
+		rules: map[string][]securityGroupRuleItem{
+			"sg-1": {{Protocol: "tcp", Action: "accept", Val1: "80", Val2: "80"}},
+		},
+	}
+}
+
+func TestExportManifest(t *testing.T) {
+	m, warnings := exportManifest("pek3", exportedFixture())
+	if err := m.validate(); err != nil {
+		t.Fatal("exported manifest is invalid,", err)
+	}
+	if m.Zone != "pek3" || m.Tag != "prod" {
+		t.Error("zone and tag, got=", m.Zone, m.Tag)
+	}
+	if len(m.Instances) != 2 || m.Instances[0].Name != "web" || m.Instances[0].Count != 2 || m.Instances[0].LoginKeyPair != "kp-1" {
+		t.Errorf("instances, got=%+v", m.Instances)
+	}
+	if len(m.Volumes) != 1 || m.Volumes[0].Name != "vol-1" || m.Volumes[0].VolumeType != "2" {
+		t.Errorf("volumes, got=%+v", m.Volumes)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "volume vol-1") {
+		t.Errorf("warnings, got=%q", warnings)
+	}
+	if len(m.SecurityGroups) != 1 || len(m.SecurityGroups[0].Rules) != 1 {
+		t.Errorf("security groups, got=%+v", m.SecurityGroups)
+	}
+}
+
+func TestExportUntagged(t *testing.T) {
+	r := exportedFixture()
+	r.eips[0].Tags = nil
+	m, warnings := exportManifest("pek3", r)
+	if len(m.Eips) != 0 || len(m.Volumes) != 1 {
+		t.Errorf("eip without the tag should not be exported, got=%+v", m.Eips)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[1], "eip eip-1 has no tag prod") {
+		t.Errorf("warnings, got=%q", warnings)
+	}
+
+	//without tag, every attached resource is exported
+	r.tag = ""
+	if m, _ := exportManifest("pek3", r); len(m.Eips) != 1 {
+		t.Errorf("eips, got=%+v", m.Eips)
+	}
+}
+
+func TestSelectorTag(t *testing.T) {
+	items := exportedFixture().instances
+	cases := map[string]string{
+		"tag=prod":          "prod",
+		"tag=tag-1":         "prod",
+		"tag=prod,name=web": "",
+		"status=running":    "",
+		"name=web,tag=prod": "",
+		"tag=missing":       "",
+	}
+	for s, expected := range cases {
+		sel, err := parseSelector(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := selectorTag(sel, items); got != expected {
+			t.Errorf("%s, got=%s, expected=%s", s, got, expected)
+		}
+	}
+}
+
+// TestExportRoundTrip plans the exported manifest against the exported resources, nothing should change.
+func TestExportRoundTrip(t *testing.T) {
+	r := exportedFixture()
+	r.volumes[0].VolumeName = "data"
+	m, _ := exportManifest("pek3", r)
+	if !m.pruneUnlisted() {
+		t.Fatal("manifest of tag should prune")
+	}
+
+	rulesOf := func(id string) ([]securityGroupRuleItem, error) { return r.rules[id], nil }
+	sgChanges, err := diffSecurityGroups(m, r.securityGroups, rulesOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := orderChanges(diffInstances(m, r.instances), diffVolumes(m, r.volumes), diffEips(m, r.eips), sgChanges)
+	if len(changes) != 0 {
+		t.Errorf("round trip should not change anything, got=%q", changeStrings(changes))
+	}
+}
+
+func TestHclName(t *testing.T) {
+	used := make(map[string]bool)
+	cases := []struct{ name, id, expected string }{
+		{"web", "i-1", "web"},
+		{"web", "i-2", "web_2"},
+		{"1 Web.Server", "i-3", "_1_web_server"},
+		{"", "i-4", "i-4"},
+		{"数据库", "i-5", "i-5"},
+	}
+	for _, c := range cases {
+		if got := hclName(used, "qingcloud_instance", c.name, c.id); got != c.expected {
+			t.Errorf("%s, got=%s, expected=%s", c.name, got, c.expected)
+		}
+	}
+	if got := hclString("a${b}"); got != `"a$${b}"` {
+		t.Error("escape interpolation, got=", got)
+	}
+}
+
+func TestExportHcl(t *testing.T) {
+	hcl := exportHcl(exportedFixture())
+	expected := []string{
+		"resource \"qingcloud_security_group\" \"web\" {\n  name = \"web\"\n}\n",
+		"  security_group_id = qingcloud_security_group.web.id\n",
+		"  eip_id            = qingcloud_eip.web.id\n",
+		"  volume_ids        = [qingcloud_volume.vol-1.id]\n",
+		"resource \"qingcloud_instance\" \"web_2\" {\n",
+		"  security_group_id = \"sg-0\"\n",
+		"# terraform import qingcloud_instance.db i-3\n",
+		"# terraform import qingcloud_eip.web eip-1\n",
+	}
+	for _, s := range expected {
+		if !strings.Contains(hcl, s) {
+			t.Errorf("expected %q in:\n%s", s, hcl)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+)
+
+var validImageProvider = []string{"system", "self"}
+var validOsFamily = []string{"centos", "ubuntu", "debian", "fedora", "opensuse", "windows", "freebsd", "coreos", "arch"}
+var validImageStatus = []string{"pending", "available", "deprecated", "suspended", "deleted", "ceased"}
+
+func addImageCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "images",
+		Short: "Manage images, describe, capture from instance, modify and delete image",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch image list, filter by provider, os family, status etc.",
+		&describeImageCmd{instanceCmd: instanceCmd{action: "DescribeImages"}}))
+	cmd.AddCommand(newCommand("capture", "Capture an image from a stopped instance",
+		&captureInstanceCmd{instanceCmd: instanceCmd{action: "CaptureInstance"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many self images which given image id",
+		&deleteImageCmd{instanceCmd: instanceCmd{action: "DeleteImages"}}))
+	cmd.AddCommand(newCommand("modify-attributes", "Modify the name and description of a self image",
+		&modifyImageAttributesCmd{instanceCmd: instanceCmd{action: "ModifyImageAttributes"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeImageCmd)(nil)
+var _ QingCloudCmd = (*captureInstanceCmd)(nil)
+var _ QingCloudCmd = (*deleteImageCmd)(nil)
+var _ QingCloudCmd = (*modifyImageAttributesCmd)(nil)
+
+// imageItem is one element of image_set in DescribeImages response.
+type imageItem struct {
+	ImageId   string `json:"image_id"`
+	ImageName string `json:"image_name"`
+	OsFamily  string `json:"os_family"`
+	Platform  string `json:"platform"`
+	Status    string `json:"status"`
+}
+
+func describeImages(param *describeImageCmd) ([]imageItem, error) {
+	type response struct {
+		ImageSet []imageItem `json:"image_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.ImageSet, nil
+}
+
+// registerImageIdCompletion completes flagName with the available system and self image ids, described by os and name.
+func registerImageIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "images", func() ([]string, error) {
+		var tmp []string
+		for _, provider := range validImageProvider {
+			items, err := describeImages(&describeImageCmd{
+				instanceCmd: completionInstanceCmd("DescribeImages"),
+				Provider:    provider,
+				Status:      []string{"available"},
+				Limit:       100,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range items {
+				tmp = append(tmp, fmt.Sprintf("%s\t%s %s", v.ImageId, v.OsFamily, v.ImageName))
+			}
+		}
+		return tmp, nil
+	})
+}
+
+type describeImageCmd struct {
+	instanceCmd
+	ImageIds      []string `name:"images" usage:"image id[s] which want to fetch. Multiple images set like --images img1 --images img2"`
+	Provider      string   `name:"provider" default:"system" usage:"image provider, system: provided by QingCloud, self: captured by yourself"`
+	OsFamily      string   `name:"os_family" usage:"filter by os family, such as centos, ubuntu, windows"`
+	ProcessorType string   `name:"processor_type" usage:"filter by processor type, 64bit or 32bit"`
+	Status        []string `name:"status" usage:"image status[es] which want to fetch. Multiple status --status st1 --status st2"`
+	SearchWord    string   `name:"search_word" usage:"search keyword, image id, name are supported"`
+	Verbose       bool     `name:"verbose" default:"false" usage:"show detail information or not"`
+	Offset        int64    `name:"offset" default:"0" usage:"matched image offset"`
+	Limit         int64    `name:"limit" default:"20" usage:"matched image limit, default is 20, max is 100"`
+}
+
+func (dic *describeImageCmd) Send() error {
+	if !validParam(validImageProvider, dic.Provider) {
+		fmt.Println("provider is invalid, must be one of", validImageProvider)
+		os.Exit(0)
+	}
+	if dic.Limit < 20 || dic.Limit > 100 {
+		dic.Limit = 20
+	}
+	return dic.send(dic)
+}
+
+func (dic *describeImageCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dic), reflect.ValueOf(*dic), reflect.ValueOf(dic), cmd))
+
+	//for completion
+	registerImageIdCompletion(cmd, "images")
+
+	flagName := "provider"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validImageProvider, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "os_family"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validOsFamily, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "processor_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"64bit", "32bit"}, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "status"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validImageStatus, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type captureInstanceCmd struct {
+	instanceCmd
+	InstanceId string `name:"instance" required:"1" usage:"the stopped instance id which want to capture"`
+	ImageName  string `name:"image_name" usage:"the new image name"`
+	Wait       bool   `name:"wait" local:"1" default:"false" usage:"wait until the image is available"`
+}
+
+func (cic *captureInstanceCmd) Send() error {
+	if !cic.Wait {
+		return cic.send(cic)
+	}
+
+	type response struct {
+		apiResponse
+		ImageId string `json:"image_id"`
+	}
+	resp := response{}
+	if err := cic.request(cic, &resp); err != nil {
+		return err
+	}
+	fmt.Println("capturing", cic.InstanceId, "to image", resp.ImageId)
+	if err := waitJob(resp.JobId); err != nil {
+		return err
+	}
+	fmt.Println("image", resp.ImageId, "is available")
+	return nil
+}
+
+func (cic *captureInstanceCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*cic), reflect.ValueOf(*cic), reflect.ValueOf(cic), cmd))
+
+	//for completion
+	registerInstanceIdCompletion(cmd, "instance")
+}
+
+type deleteImageCmd struct {
+	instanceCmd
+	ImageIds []string `name:"images" required:"1" usage:"image id[s] which want to delete. Multiple images, --images img1 --images img2"`
+}
+
+func (dic *deleteImageCmd) Send() error {
+	return dic.send(dic)
+}
+
+func (dic *deleteImageCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dic), reflect.ValueOf(*dic), reflect.ValueOf(dic), cmd))
+
+	//for completion
+	registerImageIdCompletion(cmd, "images")
+}
+
+type modifyImageAttributesCmd struct {
+	instanceCmd
+	ImageId     string `name:"image" required:"1" usage:"the image id which want to modify"`
+	ImageName   string `name:"image_name" usage:"the new image name"`
+	Description string `name:"description" usage:"the new image description"`
+}
+
+func (mic *modifyImageAttributesCmd) Send() error {
+	return mic.send(mic)
+}
+
+func (mic *modifyImageAttributesCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*mic), reflect.ValueOf(*mic), reflect.ValueOf(mic), cmd))
+
+	//for completion
+	registerImageIdCompletion(cmd, "image")
+}
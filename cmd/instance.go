@@ -1,16 +1,18 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
 	"time"
 )
 
@@ -18,6 +20,9 @@ func addInstanceCmd(root *cobra.Command) {
 	root.AddCommand(newDescribeInstanceCmd())
 	root.AddCommand(newRunInstanceCmd())
 	root.AddCommand(newTerminateInstanceCmd())
+	root.AddCommand(newResizeInstanceCmd())
+	root.AddCommand(newModifyInstanceAttributesCmd())
+	root.AddCommand(newResetInstanceCmd())
 }
 
 func newDescribeInstanceCmd() *cobra.Command {
@@ -73,12 +78,69 @@ func newTerminateInstanceCmd() *cobra.Command {
 	return cmd
 }
 
+func newResizeInstanceCmd() *cobra.Command {
+	param := &resizeInstanceCmd{
+		instanceCmd: instanceCmd{
+			action: "ResizeInstances",
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "resize-instances",
+		Short: "Resize one or many stopped instances by instance type or cpu and memory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return param.Send()
+		},
+	}
+	param.Build(cmd)
+	return cmd
+}
+
+func newModifyInstanceAttributesCmd() *cobra.Command {
+	param := &modifyInstanceAttributesCmd{
+		instanceCmd: instanceCmd{
+			action: "ModifyInstanceAttributes",
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "modify-instance-attributes",
+		Short: "Modify the name and description of an instance",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return param.Send()
+		},
+	}
+	param.Build(cmd)
+	return cmd
+}
+
+func newResetInstanceCmd() *cobra.Command {
+	param := &resetInstanceCmd{
+		instanceCmd: instanceCmd{
+			action: "ResetInstances",
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "reset-instances",
+		Short: "Reset the os disk of one or many instances to the initial state of their image",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return param.Send()
+		},
+	}
+	param.Build(cmd)
+	return cmd
+}
+
 var _ QingCloudCmd = (*describeInstanceCmd)(nil)
 var _ QingCloudCmd = (*runInstanceCmd)(nil)
 var _ QingCloudCmd = (*terminateInstanceCmd)(nil)
+var _ QingCloudCmd = (*resizeInstanceCmd)(nil)
+var _ QingCloudCmd = (*modifyInstanceAttributesCmd)(nil)
+var _ QingCloudCmd = (*resetInstanceCmd)(nil)
 
 type instanceCmd struct {
 	action            string
+	timeout           time.Duration //http timeout of request, default is one minute
+	skipZoneCheck     bool
+	post              bool //send the parameters in the POST body, never in the url
 	zone              string
 	timeStamp         string
 	qyAccessKeyId     string
@@ -93,15 +155,17 @@ func (ic *instanceCmd) commonParam() *url.Values {
 	val := &url.Values{}
 	val.Add("action", ic.action)
 
-	//如果使用配置文件里的zone，如果参数指定了，则使用参数的
-	if len(zone) == 0 {
-		ic.zone = viper.GetString("zone")
-	} else {
-		ic.zone = zone
+	//如果使用配置文件里的zone，如果参数指定了，则使用参数的; 请求预设了zone时(如多区域请求)使用预设的
+	if len(ic.zone) == 0 {
+		if len(zone) == 0 {
+			ic.zone = viper.GetString("zone")
+		} else {
+			ic.zone = zone
+		}
 	}
 
-	if !validParam(validZoneList, ic.zone) {
-		fmt.Println("zone is invalid, must be one of", validZoneList)
+	if !ic.skipZoneCheck && !validZone(ic.zone) {
+		fmt.Println("zone is invalid, must be one of", availableZones(false))
 		os.Exit(0)
 	}
 
@@ -128,6 +192,217 @@ func (ic *instanceCmd) commonParam() *url.Values {
 	return val
 }
 
+// request sends the action of param, a pointer to the struct which embeds ic,
+// and decodes the response into out.
+func (ic *instanceCmd) request(param interface{}, out interface{}) error {
+	data, err := ic.requestData(param)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(data, out)
+}
+
+// requestData sends the action of param and returns the raw response.
+func (ic *instanceCmd) requestData(param interface{}) ([]byte, error) {
+	val := ic.commonParam()
+	typeOf := reflect.TypeOf(param).Elem()
+	if err := buildUrlValues(typeOf, reflect.ValueOf(param).Elem(), reflect.ValueOf(param), val); err != nil {
+		return nil, err
+	}
+	timeout := ic.timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	if ic.post {
+		return sendHttpPostData(val, []byte(ic.qySecretAccessKey), timeout)
+	}
+	return sendHttpRequestData(val, []byte(ic.qySecretAccessKey), timeout)
+}
+
+// send sends the action of param, a pointer to the struct which embeds ic,
+// and prints the response.
+func (ic *instanceCmd) send(param interface{}) error {
+	if ic.post {
+		data, err := ic.requestData(param)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		printPrettyJson(data)
+		return nil
+	}
+	val := ic.commonParam()
+	typeOf := reflect.TypeOf(param).Elem()
+	mustBeOk(buildUrlValues(typeOf, reflect.ValueOf(param).Elem(), reflect.ValueOf(param), val))
+	return sendHttpRequest(val, []byte(ic.qySecretAccessKey))
+}
+
+// instanceItem is one element of instance_set in DescribeInstances response.
+type instanceItem struct {
+	InstanceId    string    `json:"instance_id"`
+	InstanceName  string    `json:"instance_name"`
+	InstanceType  string    `json:"instance_type"`
+	VcpusCurrent  int64     `json:"vcpus_current"`
+	MemoryCurrent int64     `json:"memory_current"`
+	InstanceClass int64     `json:"instance_class"`
+	Status        string    `json:"status"`
+	KeyPairIds    []string  `json:"keypair_ids"`
+	Tags          []tagItem `json:"tags"`
+	Image         struct {
+		ImageId  string `json:"image_id"`
+		OsFamily string `json:"os_family"`
+	} `json:"image"`
+	Eip struct {
+		EipId   string `json:"eip_id"`
+		EipAddr string `json:"eip_addr"`
+	} `json:"eip"`
+	Vxnets        []instanceVxnet `json:"vxnets"`
+	VolumeIds     []string        `json:"volume_ids"`
+	SecurityGroup struct {
+		SecurityGroupId   string `json:"security_group_id"`
+		SecurityGroupName string `json:"security_group_name"`
+	} `json:"security_group"`
+}
+
+// instanceVxnet is one element of the vxnets of instanceItem.
+type instanceVxnet struct {
+	VxnetId   string `json:"vxnet_id"`
+	VxnetName string `json:"vxnet_name"`
+	PrivateIp string `json:"private_ip"`
+	NicId     string `json:"nic_id"`
+}
+
+func (item instanceItem) privateIp() string {
+	for _, v := range item.Vxnets {
+		if len(v.PrivateIp) != 0 {
+			return v.PrivateIp
+		}
+	}
+	return ""
+}
+
+// fields returns the values of instance matched by selector, keys are tag, name, status, type and vxnet.
+func (item instanceItem) fields() map[string][]string {
+	fields := map[string][]string{
+		"tag":    tagValues(item.Tags),
+		"name":   {item.InstanceName},
+		"status": {item.Status},
+		"type":   {item.InstanceType},
+	}
+	for _, v := range item.Vxnets {
+		fields["vxnet"] = append(fields["vxnet"], v.VxnetId, v.VxnetName)
+	}
+	return fields
+}
+
+func printInstanceTable(items []instanceItem) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE_ID\tNAME\tSTATUS\tTYPE\tPRIVATE_IP\tEIP")
+	for _, v := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", v.InstanceId, v.InstanceName, v.Status, v.InstanceType, v.privateIp(), v.Eip.EipAddr)
+	}
+	w.Flush()
+}
+
+func describeInstances(param *describeInstanceCmd) ([]instanceItem, error) {
+	type response struct {
+		InstanceSet []instanceItem `json:"instance_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.InstanceSet, nil
+}
+
+// describeAllInstances fetches all instances matched param page by page.
+func describeAllInstances(param *describeInstanceCmd) ([]instanceItem, error) {
+	type response struct {
+		InstanceSet []instanceItem `json:"instance_set"`
+		TotalCount  int64          `json:"total_count"`
+	}
+
+	var items []instanceItem
+	for {
+		param.Offset = int64(len(items))
+		param.Limit = 100
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		items = append(items, resp.InstanceSet...)
+		if len(resp.InstanceSet) == 0 || int64(len(items)) >= resp.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+// instanceIdsCmd is used internally for the actions which only need instance ids, such as StopInstances.
+type instanceIdsCmd struct {
+	instanceCmd
+	InstanceIds []string `name:"instances"`
+}
+
+// doInstanceJob sends action for instanceIds and waits until the job is done.
+func doInstanceJob(action string, instanceIds []string) error {
+	param := &instanceIdsCmd{
+		instanceCmd: instanceCmd{
+			action: action,
+		},
+		InstanceIds: instanceIds,
+	}
+	resp := apiResponse{}
+	if err := param.request(param, &resp); err != nil {
+		return err
+	}
+	return waitJob(resp.JobId)
+}
+
+// checkInstanceSize exits if neither the instance type nor the cpu and memory is valid.
+func checkInstanceSize(instanceType string, cpu, memory int64) {
+	if cpu > 0 && memory > 0 {
+		if !validInt64Param(validCpuNumber, cpu) {
+			fmt.Println("CPU number is invalid, must be one of", validCpuNumber)
+			os.Exit(0)
+		}
+
+		if !validInt64Param(validMemoryNumber, memory) {
+			fmt.Println("memory size is invalid, must be one of", validMemoryNumber)
+			os.Exit(0)
+		}
+	} else if len(instanceType) != 0 {
+		if !validParam(validInstanceType, instanceType) {
+			fmt.Println("instance type is invalid, must be one of", validInstanceType)
+			os.Exit(0)
+		}
+	}
+}
+
+func registerInstanceSizeCompletion(cmd *cobra.Command) {
+	flagName := "cpu"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var tmp []string
+		for _, v := range validCpuNumber {
+			tmp = append(tmp, strconv.Itoa(int(v)))
+		}
+		return tmp, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "memory"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var tmp []string
+		for _, v := range validMemoryNumber {
+			tmp = append(tmp, strconv.Itoa(int(v)))
+		}
+		return tmp, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "instance_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validInstanceType, cobra.ShellCompDirectiveDefault
+	})
+}
+
 type describeInstanceCmd struct {
 	instanceCmd
 	InstanceIds          []string `name:"instances" usage:"instance id[s] which want to fetch. Multiple instances set like --instances ins1 --instances ins2"`
@@ -147,6 +422,8 @@ type describeInstanceCmd struct {
 	Verbose              bool     `name:"verbose" default:"false" usage:"how debug information or not"`
 	Offset               int64    `name:"offset" default:"0" usage:"matched instance offset"`
 	Limit                int64    `name:"limit" default:"20" usage:"matched instance limit, default is 20, max is 100"`
+	Output               string   `name:"output" local:"1" default:"json" usage:"output format, json or table"`
+	EstimateCost         bool     `name:"estimate-cost" local:"1" default:"false" usage:"estimate the monthly cost of all matched instances instead of listing them"`
 }
 
 func (dic *describeInstanceCmd) Send() error {
@@ -165,6 +442,27 @@ func (dic *describeInstanceCmd) Send() error {
 		dic.Limit = 20
 	}
 
+	if dic.EstimateCost {
+		items, err := describeAllInstances(dic)
+		if err != nil {
+			return err
+		}
+		return estimateCost(groupInstanceSizes(items), 0)
+	}
+
+	if !validParam(validOutputFormat, dic.Output) {
+		fmt.Println("output format is invalid, must be one of", validOutputFormat)
+		os.Exit(0)
+	}
+	if dic.Output == "table" {
+		items, err := describeInstances(dic)
+		if err != nil {
+			return err
+		}
+		printInstanceTable(items)
+		return nil
+	}
+
 	mustBeOk(buildUrlValues(reflect.TypeOf(*dic), reflect.ValueOf(*dic), reflect.ValueOf(dic), val))
 	return sendHttpRequest(val, []byte(dic.qySecretAccessKey))
 }
@@ -177,6 +475,11 @@ func (dic *describeInstanceCmd) Build(cmd *cobra.Command) {
 	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return validInstanceClassList, cobra.ShellCompDirectiveDefault
 	})
+
+	flagName = "output"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validOutputFormat, cobra.ShellCompDirectiveDefault
+	})
 }
 
 type runInstanceCmd struct {
@@ -187,14 +490,14 @@ type runInstanceCmd struct {
 	Memory               int64    `name:"memory" usage:"memory size, unit MB"`
 	OsDiskSize           int64    `name:"os_disk_size" usage:"the size of OS disk, unit GB"`
 	Count                int64    `name:"count" usage:"the count of instance you want to create with the same configuration"`
-	InstanceName         string   `name:"instance_name" usage:"the instance name"`
+	InstanceName         string   `name:"instance_name" usage:"the instance name, may be a template such as web-{{.Index}}-{{.Zone}} for unique names of multiple instances"`
 	LoginMode            string   `name:"login_mode" usage:"login mode. If linux, keypair and password were valid. Password only when windows"`
 	LoginKeyPair         string   `name:"login_keypair" usage:"login keypair"`
 	LoginPasswd          string   `name:"login_passwd" usage:"login password"`
 	Vxnets               []string `name:"vxnets" usage:"the private network id want to join"`
 	SecurityGroup        string   `name:"security_group" usage:"security group want to join"`
 	Volumes              []string `name:"volumes" usage:"the disk id to auto mount after created instance.If was specified, the count parameter must be 1."`
-	Hostname             string   `name:"hostname" usage:"the host name"`
+	Hostname             string   `name:"hostname" usage:"the host name, may be a template such as web-{{.Index}}"`
 	NeedNewSid           bool     `name:"need_newsid" default:"true" usage:"generate new sid or not"`
 	InstanceClass        string   `name:"instance_class" usage:"instance performance category, 0: high performance, 1: super high performance,101: basic, 201: enterprise"`
 	CpuModel             string   `name:"cpu_model" usage:"cpu model"`
@@ -216,26 +519,21 @@ type runInstanceCmd struct {
 	CipherAlg            string   `name:"cipher_alg" default:"aes256" usage:"os disk cipher method. aes256 only."`
 	Months               int64    `name:"months" usage:"month"`
 	AutoRenew            bool     `name:"auto_renew" default:"false" usage:"auto renew or not"`
+	Tags                 []string `name:"tag" local:"1" usage:"the tag name attached to the created instances, created if not exists, such as owner=alice. Multiple tags, --tag t1 --tag t2"`
+	SkipQuotaCheck       bool     `name:"skip-quota-check" local:"1" default:"false" usage:"do not check the quota left of instance, cpu and memory before creating, volume quota is not used by run-instances"`
+	EstimateCost         bool     `name:"estimate-cost" local:"1" default:"false" usage:"print the hourly and monthly cost of the instances, nothing is created"`
+	Template             string   `name:"template" local:"1" usage:"the launch template saved by templates save, the flags given override the template"`
+	IndexStart           int64    `name:"index-start" local:"1" default:"1" usage:"the first {{.Index}} of instance_name and hostname templates"`
+	Zones                []string `name:"zones" local:"1" usage:"spread the instances across zones, image, vxnet and other ids are mapped by zone_ids of config. Multiple zones, --zones pek3a,pek3b"`
+	Spread               string   `name:"spread" local:"1" default:"even" usage:"how to spread count across zones, even: divided evenly, per-zone: count in every zone"`
+
+	//quiet is set when running in multiple zones, the merged report is printed instead of every response
+	quiet bool
 }
 
 func (ric *runInstanceCmd) Send() error {
 	val := ric.commonParam()
-	if ric.CPU > 0 && ric.Memory > 0 {
-		if !validInt64Param(validCpuNumber, ric.CPU) {
-			fmt.Println("CPU number is invalid, must be one of", validCpuNumber)
-			os.Exit(0)
-		}
-
-		if !validInt64Param(validMemoryNumber, ric.Memory) {
-			fmt.Println("memory size is invalid, must be one of", validMemoryNumber)
-			os.Exit(0)
-		}
-	} else if len(ric.InstanceType) != 0 {
-		if !validParam(validInstanceType, ric.InstanceType) {
-			fmt.Println("instance type is invalid, must be one of", validInstanceType)
-			os.Exit(0)
-		}
-	}
+	checkInstanceSize(ric.InstanceType, ric.CPU, ric.Memory)
 
 	if len(ric.InstanceClass) != 0 {
 		if !validParam(validInstanceClassList, ric.InstanceClass) {
@@ -269,38 +567,379 @@ func (ric *runInstanceCmd) Send() error {
 		}
 	}
 
+	if ric.EstimateCost {
+		count := ric.Count
+		if zones := splitZones(ric.Zones); len(zones) != 0 && ric.Spread == "per-zone" {
+			count *= int64(len(zones))
+		}
+		return estimateCost([]instanceSize{{
+			InstanceType:  ric.InstanceType,
+			CPU:           ric.CPU,
+			Memory:        ric.Memory,
+			OsDiskSize:    ric.OsDiskSize,
+			InstanceClass: ric.InstanceClass,
+			Count:         count,
+		}}, ric.Months)
+	}
+
+	if len(ric.Zones) != 0 {
+		return ric.runZones()
+	}
+
+	if !ric.SkipQuotaCheck {
+		if err := ric.checkQuota(); err != nil {
+			return err
+		}
+	}
+
+	if isNameTemplate(ric.InstanceName) || isNameTemplate(ric.Hostname) {
+		_, err := ric.runNamed()
+		return err
+	}
+
+	if len(ric.Tags) != 0 {
+		_, err := ric.runAndTag()
+		return err
+	}
+
 	mustBeOk(buildUrlValues(reflect.TypeOf(*ric), reflect.ValueOf(*ric), reflect.ValueOf(ric), val))
 	return sendHttpRequest(val, []byte(ric.qySecretAccessKey))
 }
 
-func (ric *runInstanceCmd) Build(cmd *cobra.Command) {
-	mustBeOk(buildCobraFlags(reflect.TypeOf(*ric), reflect.ValueOf(*ric), reflect.ValueOf(ric), cmd))
+// checkQuota checks the quota left of instance, cpu and memory against count instances of the requested size,
+// so a batch fails before any instance is created instead of halfway. The volume quota is not checked because
+// run-instances creates no volume, --volumes attaches existing ones and the os disk is a part of the instance.
+// cpu and memory are not checked if the size of instance type is unknown, such as s1.small.r1.
+func (ric *runInstanceCmd) checkQuota() error {
+	cpu, memory := ric.CPU, ric.Memory
+	if (cpu == 0 || memory == 0) && len(ric.InstanceType) != 0 {
+		cpu, memory, _ = instanceTypeSize(ric.InstanceType)
+	}
+	required := map[string]int64{"instance": ric.Count}
+	if cpu > 0 && memory > 0 {
+		required["cpu"] = ric.Count * cpu
+		required["memory"] = ric.Count * memory
+	}
 
-	//for completion
-	flagName := "cpu"
-	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		var tmp []string
-		for _, v := range validCpuNumber {
-			tmp = append(tmp, strconv.Itoa(int(v)))
+	var resourceTypes []string
+	for t := range required {
+		resourceTypes = append(resourceTypes, t)
+	}
+	left, err := getQuotaLeft(ric.zone, resourceTypes)
+	if err != nil {
+		return fmt.Errorf("check quota failed, %v, use --skip-quota-check to skip", err)
+	}
+	return checkQuota(required, left)
+}
+
+// runInstances sends RunInstances, prints the response and returns the created instance ids and the job id.
+func (ric *runInstanceCmd) runInstances() ([]string, string, error) {
+	type response struct {
+		apiResponse
+		Instances []string `json:"instances"`
+	}
+	data, err := ric.requestData(ric)
+	if err != nil {
+		return nil, "", err
+	}
+	if !ric.quiet {
+		printPrettyJson(data)
+	}
+	resp := response{}
+	if err := decodeResponse(data, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Instances, resp.JobId, nil
+}
+
+// runAndTag creates the instances, then attaches the tags to them after the job succeeds.
+func (ric *runInstanceCmd) runAndTag() ([]string, error) {
+	instances, jobId, err := ric.runInstances()
+	if err != nil {
+		return nil, err
+	}
+	if err := waitZoneJob(ric.zone, jobId); err != nil {
+		return instances, err
+	}
+	return instances, ric.attachRunTags(instances)
+}
+
+// attachRunTags attaches the tags of ric to the created instances, created if not exists.
+func (ric *runInstanceCmd) attachRunTags(instances []string) error {
+	var tagIds []string
+	for _, name := range ric.Tags {
+		tagId, err := ensureTag(ric.zone, name)
+		if err != nil {
+			return err
 		}
-		return tmp, cobra.ShellCompDirectiveDefault
-	})
+		tagIds = append(tagIds, tagId)
+	}
+	if err := attachTags(ric.zone, tagIds, instances); err != nil {
+		return err
+	}
+	if !ric.quiet {
+		fmt.Println("tag(s)", ric.Tags, "attached to", instances)
+	}
+	return nil
+}
 
-	flagName = "memory"
-	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		var tmp []string
-		for _, v := range validMemoryNumber {
-			tmp = append(tmp, strconv.Itoa(int(v)))
+// instanceNameData is the data of instance_name and hostname templates, such as web-{{.Index}}-{{.Zone}}.
+type instanceNameData struct {
+	Index int64
+	Zone  string
+	Count int64
+}
+
+func isNameTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// renderInstanceNames renders the template for count instances, indexes start from start.
+// Names must be unique when there are more than one instance, so a lookup by name is never ambiguous.
+func renderInstanceNames(tpl, zone string, start, count int64) ([]string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(tpl)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for i := int64(0); i < count; i++ {
+		var b strings.Builder
+		if err := t.Execute(&b, instanceNameData{Index: start + i, Zone: zone, Count: count}); err != nil {
+			return nil, err
 		}
-		return tmp, cobra.ShellCompDirectiveDefault
-	})
+		if seen[b.String()] {
+			return nil, fmt.Errorf("%s renders duplicate name %s, use {{.Index}} for unique names", tpl, b.String())
+		}
+		seen[b.String()] = true
+		names = append(names, b.String())
+	}
+	return names, nil
+}
 
-	flagName = "instance_type"
-	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return validInstanceType, cobra.ShellCompDirectiveDefault
-	})
+// runNamed splits the request into one RunInstances of every instance, with the rendered name and hostname,
+// because the hostname can not be modified after created. All names are rendered before any instance is created.
+func (ric *runInstanceCmd) runNamed() ([]string, error) {
+	names, hostnames := make([]string, ric.Count), make([]string, ric.Count)
+	var err error
+	if isNameTemplate(ric.InstanceName) {
+		if names, err = renderInstanceNames(ric.InstanceName, ric.zone, ric.IndexStart, ric.Count); err != nil {
+			return nil, fmt.Errorf("invalid instance_name, %v", err)
+		}
+	}
+	if isNameTemplate(ric.Hostname) {
+		if hostnames, err = renderInstanceNames(ric.Hostname, ric.zone, ric.IndexStart, ric.Count); err != nil {
+			return nil, fmt.Errorf("invalid hostname, %v", err)
+		}
+	}
+
+	var instances, jobs []string
+	for i := range names {
+		one := *ric
+		one.Count = 1
+		if len(names[i]) != 0 {
+			one.InstanceName = names[i]
+		}
+		if len(hostnames[i]) != 0 {
+			one.Hostname = hostnames[i]
+		}
+		created, jobId, err := one.runInstances()
+		if err != nil {
+			return instances, fmt.Errorf("%v, %d instance(s) created: %v", err, len(instances), instances)
+		}
+		instances, jobs = append(instances, created...), append(jobs, jobId)
+	}
+	if len(ric.Tags) == 0 {
+		return instances, nil
+	}
+	for _, jobId := range jobs {
+		if err := waitZoneJob(ric.zone, jobId); err != nil {
+			return instances, err
+		}
+	}
+	return instances, ric.attachRunTags(instances)
+}
+
+var validSpread = []string{"even", "per-zone"}
+
+// splitZones returns the zones of --zones, such as --zones pek3a,pek3b --zones sh1a, without duplicates.
+func splitZones(list []string) []string {
+	var zones []string
+	for _, v := range list {
+		for _, z := range strings.Split(v, ",") {
+			if len(z) != 0 && !validParam(zones, z) {
+				zones = append(zones, z)
+			}
+		}
+	}
+	return zones
+}
+
+// spreadCounts returns the count of instances in every zone. even divides count across zones, the first zones
+// get one more if count is not divisible, per-zone creates count instances in every zone.
+func spreadCounts(zones []string, count int64, spread string) []int64 {
+	counts := make([]int64, len(zones))
+	for i := range zones {
+		if spread == "per-zone" {
+			counts[i] = count
+			continue
+		}
+		counts[i] = count / int64(len(zones))
+		if int64(i) < count%int64(len(zones)) {
+			counts[i]++
+		}
+	}
+	return counts
+}
+
+// mapZoneId returns the id of the same resource in another zone by the zone_ids of config, such as
+//
+//	zone_ids:
+//	  pek3b:
+//	    img-aaaaaaaa: img-bbbbbbbb
+//
+// id is kept if it is not mapped.
+func mapZoneId(ids map[string]string, id string) string {
+	if mapped, ok := ids[id]; ok && len(mapped) != 0 {
+		return mapped
+	}
+	return id
+}
+
+// zoneRun is the result of run-instances in one zone.
+type zoneRun struct {
+	zone      string
+	count     int64
+	instances []string
+	err       error
+}
+
+// forZone returns a copy of ric which creates count instances in zone, the ids are mapped by ids.
+// indexStart keeps {{.Index}} of name templates unique across zones.
+func (ric *runInstanceCmd) forZone(zone string, count, indexStart int64, ids map[string]string) *runInstanceCmd {
+	one := *ric
+	one.zone = zone
+	//zones are checked before running concurrently
+	one.skipZoneCheck = true
+	one.quiet = true
+	one.Zones = nil
+	one.Count = count
+	one.IndexStart = indexStart
+	one.ImageId = mapZoneId(ids, ric.ImageId)
+	one.SecurityGroup = mapZoneId(ids, ric.SecurityGroup)
+	one.LoginKeyPair = mapZoneId(ids, ric.LoginKeyPair)
+	one.Vxnets = nil
+	for _, v := range ric.Vxnets {
+		one.Vxnets = append(one.Vxnets, mapZoneId(ids, v))
+	}
+	return &one
+}
+
+// runZone creates the instances of ric in its zone, the same way as a single zone run does.
+func (ric *runInstanceCmd) runZone() ([]string, error) {
+	if isNameTemplate(ric.InstanceName) || isNameTemplate(ric.Hostname) {
+		return ric.runNamed()
+	}
+	if len(ric.Tags) != 0 {
+		return ric.runAndTag()
+	}
+	instances, _, err := ric.runInstances()
+	return instances, err
+}
+
+// runZones spreads the instances across the zones of --zones, runs the zones concurrently
+// and prints the merged result. Quotas of all zones are checked before any instance is created.
+func (ric *runInstanceCmd) runZones() error {
+	zones := splitZones(ric.Zones)
+	for _, z := range zones {
+		if !validZone(z) {
+			return fmt.Errorf("zone %s is invalid, must be one of %v", z, availableZones(false))
+		}
+	}
+	if !validParam(validSpread, ric.Spread) {
+		fmt.Println("spread is invalid, must be one of", validSpread)
+		os.Exit(0)
+	}
+	if len(ric.Volumes) != 0 {
+		return errors.New("volumes can not be attached when running in multiple zones")
+	}
+
+	counts := spreadCounts(zones, ric.Count, ric.Spread)
+	runs := make([]*runInstanceCmd, len(zones))
+	indexStart := ric.IndexStart
+	for i, z := range zones {
+		runs[i] = ric.forZone(z, counts[i], indexStart, viper.GetStringMapString("zone_ids."+z))
+		indexStart += counts[i]
+	}
+	if !ric.SkipQuotaCheck {
+		for _, run := range runs {
+			if run.Count == 0 {
+				continue
+			}
+			if err := run.checkQuota(); err != nil {
+				return fmt.Errorf("zone %s, %v", run.zone, err)
+			}
+		}
+	}
+
+	results := make([]zoneRun, len(runs))
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		results[i] = zoneRun{zone: run.zone, count: run.Count}
+		if run.Count == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, run *runInstanceCmd) {
+			defer wg.Done()
+			results[i].instances, results[i].err = run.runZone()
+		}(i, run)
+	}
+	wg.Wait()
+	return printZoneRuns(results)
+}
+
+// printZoneRuns prints the result of every zone, and returns an error if any zone failed.
+func printZoneRuns(results []zoneRun) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ZONE\tCOUNT\tINSTANCES\tSTATUS")
+	failed, total := 0, 0
+	for _, r := range results {
+		status := "ok"
+		if r.err != nil {
+			status = r.err.Error()
+			failed++
+		}
+		total += len(r.instances)
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", r.zone, r.count, strings.Join(r.instances, ","), status)
+	}
+	w.Flush()
+	fmt.Println(total, "instance(s) created in", len(results), "zone(s)")
+	if failed != 0 {
+		return fmt.Errorf("%d of %d zone(s) failed", failed, len(results))
+	}
+	return nil
+}
 
-	flagName = "instance_class"
+func (ric *runInstanceCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ric), reflect.ValueOf(*ric), reflect.ValueOf(ric), cmd))
+	//the template fills flags before the required flags are checked
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if len(ric.Template) == 0 {
+			return nil
+		}
+		return applyTemplate(cmd, ric.Template)
+	}
+
+	//for completion
+	registerInstanceSizeCompletion(cmd)
+	registerVolumeIdCompletion(cmd, "volumes")
+	registerVxnetIdCompletion(cmd, "vxnets")
+	registerSecurityGroupIdCompletion(cmd, "security_group")
+	registerKeyPairIdCompletion(cmd, "login_keypair")
+	registerImageIdCompletion(cmd, "image_id")
+
+	flagName := "instance_class"
 	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return validInstanceClassList, cobra.ShellCompDirectiveDefault
 	})
@@ -320,6 +959,21 @@ func (ric *runInstanceCmd) Build(cmd *cobra.Command) {
 		return validUserDataType, cobra.ShellCompDirectiveDefault
 	})
 
+	flagName = "template"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return listTemplateNames(), cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "zones"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return availableZones(false), cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "spread"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validSpread, cobra.ShellCompDirectiveDefault
+	})
+
 }
 
 type terminateInstanceCmd struct {
@@ -337,44 +991,175 @@ func (tic *terminateInstanceCmd) Send() error {
 func (tic *terminateInstanceCmd) Build(cmd *cobra.Command) {
 	mustBeOk(buildCobraFlags(reflect.TypeOf(*tic), reflect.ValueOf(*tic), reflect.ValueOf(tic), cmd))
 
-	type response struct {
-		InstanceSet []struct {
-			InstanceId string `json:"instance_id"`
-		} `json:"instance_set"`
+	//for completion
+	registerInstanceIdCompletion(cmd, "instances")
+}
+
+// fetchInstanceIdCompletions returns the instance ids, described by name and status.
+func fetchInstanceIdCompletions() ([]string, error) {
+	var tmp []string
+	items, err := describeInstances(&describeInstanceCmd{
+		instanceCmd: completionInstanceCmd("DescribeInstances"),
+		Limit:       100,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range items {
+		tmp = append(tmp, fmt.Sprintf("%s\t%s (%s)", v.InstanceId, v.InstanceName, v.Status))
 	}
+	return tmp, nil
+}
 
-	//for completion
-	flagName := "instances"
-	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		var tmp []string
-		param := &describeInstanceCmd{
-			instanceCmd: instanceCmd{
-				action: "DescribeInstances",
-			},
-		}
-		val := param.commonParam()
-		mustBeOk(buildUrlValues(reflect.TypeOf(*param), reflect.ValueOf(*param), reflect.ValueOf(param), val))
+// registerInstanceIdCompletion completes flagName with the instance ids, see fetchInstanceIdCompletions.
+func registerInstanceIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "instances", fetchInstanceIdCompletions)
+}
 
-		signedStr := signature(val, []byte(param.qySecretAccessKey))
-		urlStr := concatQueryUrl(val, signedStr)
+type resizeInstanceCmd struct {
+	instanceCmd
+	InstanceIds   []string `name:"instances" required:"1" usage:"instance id[s] which want to resize. Multiple instances, --instances ins1 --instances ins2"`
+	InstanceType  string   `name:"instance_type" usage:"the new instance type.If instance_type was specified, cpu and memory were not required,otherwise both cpu and memory were required."`
+	CPU           int64    `name:"cpu" usage:"the new cpu number"`
+	Memory        int64    `name:"memory" usage:"the new memory size, unit MB"`
+	AutoStopStart bool     `name:"auto-stop-start" local:"1" default:"false" usage:"stop the running instances before resizing and start them again after resized"`
+}
 
-		client := &http.Client{Timeout: time.Minute}
-		resp, err := client.Get(urlStr)
-		if err != nil {
-			return tmp, cobra.ShellCompDirectiveDefault
+func (ric *resizeInstanceCmd) Send() error {
+	if len(ric.InstanceType) == 0 && (ric.CPU <= 0 || ric.Memory <= 0) {
+		fmt.Println("either instance_type or both cpu and memory must be specified")
+		os.Exit(0)
+	}
+	checkInstanceSize(ric.InstanceType, ric.CPU, ric.Memory)
+
+	if !ric.AutoStopStart {
+		val := ric.commonParam()
+		mustBeOk(buildUrlValues(reflect.TypeOf(*ric), reflect.ValueOf(*ric), reflect.ValueOf(ric), val))
+		return sendHttpRequest(val, []byte(ric.qySecretAccessKey))
+	}
+	return ric.resizeWithStopStart()
+}
+
+// resizeWithStopStart stops the running instances, resizes all of them, then starts the stopped ones again.
+func (ric *resizeInstanceCmd) resizeWithStopStart() error {
+	items, err := describeInstances(&describeInstanceCmd{
+		instanceCmd: instanceCmd{
+			action: "DescribeInstances",
+		},
+		InstanceIds: ric.InstanceIds,
+	})
+	if err != nil {
+		return err
+	}
+
+	var running []string
+	for _, v := range items {
+		if v.Status == "running" {
+			running = append(running, v.InstanceId)
 		}
-		defer resp.Body.Close()
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return tmp, cobra.ShellCompDirectiveDefault
+	}
+
+	if len(running) != 0 {
+		fmt.Println("stopping", running)
+		if err := doInstanceJob("StopInstances", running); err != nil {
+			return restartAfterFailure(running, err)
 		}
+	}
 
-		resp2 := response{}
-		if err = json.Unmarshal(data, &resp2); err == nil {
-			for _, v := range resp2.InstanceSet {
-				tmp = append(tmp, v.InstanceId)
-			}
+	fmt.Println("resizing", ric.InstanceIds)
+	resp := apiResponse{}
+	if err := ric.request(ric, &resp); err != nil {
+		return restartAfterFailure(running, err)
+	}
+	if err := waitJob(resp.JobId); err != nil {
+		return restartAfterFailure(running, err)
+	}
+
+	if len(running) != 0 {
+		fmt.Println("starting", running)
+		if err := doInstanceJob("StartInstances", running); err != nil {
+			return err
 		}
-		return tmp, cobra.ShellCompDirectiveDefault
+	}
+	fmt.Println("resize finished")
+	return nil
+}
+
+// restartAfterFailure starts the instances which were running before the resize, so a failed resize
+// does not leave them stopped, and returns the original error.
+func restartAfterFailure(running []string, err error) error {
+	if len(running) == 0 {
+		return err
+	}
+	fmt.Println("resize failed, starting", running)
+	if startErr := doInstanceJob("StartInstances", running); startErr != nil {
+		return fmt.Errorf("%v, and start %v failed, %v", err, running, startErr)
+	}
+	return err
+}
+
+func (ric *resizeInstanceCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ric), reflect.ValueOf(*ric), reflect.ValueOf(ric), cmd))
+
+	//for completion
+	registerInstanceSizeCompletion(cmd)
+	registerInstanceIdCompletion(cmd, "instances")
+}
+
+type modifyInstanceAttributesCmd struct {
+	instanceCmd
+	InstanceId   string `name:"instance" required:"1" usage:"the instance id which want to modify"`
+	InstanceName string `name:"instance_name" usage:"the new instance name"`
+	Description  string `name:"description" usage:"the new instance description"`
+}
+
+func (mic *modifyInstanceAttributesCmd) Send() error {
+	val := mic.commonParam()
+	mustBeOk(buildUrlValues(reflect.TypeOf(*mic), reflect.ValueOf(*mic), reflect.ValueOf(mic), val))
+	return sendHttpRequest(val, []byte(mic.qySecretAccessKey))
+}
+
+func (mic *modifyInstanceAttributesCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*mic), reflect.ValueOf(*mic), reflect.ValueOf(mic), cmd))
+
+	//for completion
+	registerInstanceIdCompletion(cmd, "instance")
+}
+
+type resetInstanceCmd struct {
+	instanceCmd
+	InstanceIds  []string `name:"instances" required:"1" usage:"instance id[s] which want to reset. Multiple instances, --instances ins1 --instances ins2"`
+	LoginMode    string   `name:"login_mode" required:"1" usage:"login mode, keypair or passwd"`
+	LoginKeyPair string   `name:"login_keypair" usage:"login keypair, required when login mode is keypair"`
+	LoginPasswd  string   `name:"login_passwd" usage:"login password, required when login mode is passwd"`
+	NeedNewSid   bool     `name:"need_newsid" default:"false" usage:"generate new sid or not, windows only"`
+}
+
+func (ric *resetInstanceCmd) Send() error {
+	if ric.LoginMode != "keypair" && ric.LoginMode != "passwd" {
+		fmt.Println("login mode must be keypair or passwd")
+		os.Exit(0)
+	}
+	if ric.LoginMode == "keypair" && len(ric.LoginKeyPair) == 0 {
+		fmt.Println("login keypair is required when login mode is keypair")
+		os.Exit(0)
+	}
+	if ric.LoginMode == "passwd" && len(ric.LoginPasswd) == 0 {
+		fmt.Println("login password is required when login mode is passwd")
+		os.Exit(0)
+	}
+	return ric.send(ric)
+}
+
+func (ric *resetInstanceCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ric), reflect.ValueOf(*ric), reflect.ValueOf(ric), cmd))
+
+	//for completion
+	registerInstanceIdCompletion(cmd, "instances")
+	registerKeyPairIdCompletion(cmd, "login_keypair")
+
+	flagName := "login_mode"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"keypair", "passwd"}, cobra.ShellCompDirectiveDefault
 	})
 }
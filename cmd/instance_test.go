@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderInstanceNames(t *testing.T) {
+	names, err := renderInstanceNames("web-{{.Index}}-{{.Zone}}", "pek3", 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, ","); got != "web-1-pek3,web-2-pek3,web-3-pek3" {
+		t.Error("got=", got)
+	}
+
+	names, err = renderInstanceNames(`db-{{printf "%02d" .Index}}`, "pek3", 9, 2)
+	if err != nil || strings.Join(names, ",") != "db-09,db-10" {
+		t.Error("printf, got=", names, err)
+	}
+
+	if _, err := renderInstanceNames("web-{{.Zone}}", "pek3", 1, 2); err == nil {
+		t.Error("duplicate names, expected an error")
+	}
+	if _, err := renderInstanceNames("web-{{.Unknown}}", "pek3", 1, 1); err == nil {
+		t.Error("unknown field, expected an error")
+	}
+	if !isNameTemplate("web-{{.Index}}") || isNameTemplate("web") {
+		t.Error("isNameTemplate")
+	}
+}
+
+func TestSpreadCounts(t *testing.T) {
+	zones := []string{"pek3a", "pek3b", "pek3c"}
+	cases := []struct {
+		count  int64
+		spread string
+		want   []int64
+	}{
+		{6, "even", []int64{2, 2, 2}},
+		{7, "even", []int64{3, 2, 2}},
+		{2, "even", []int64{1, 1, 0}},
+		{2, "per-zone", []int64{2, 2, 2}},
+	}
+	for _, c := range cases {
+		got := spreadCounts(zones, c.count, c.spread)
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Error(c.count, c.spread, "got=", got, "want=", c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestForZone(t *testing.T) {
+	ric := &runInstanceCmd{
+		ImageId:      "img-a",
+		Vxnets:       []string{"vxnet-0", "vxnet-a"},
+		LoginKeyPair: "kp-a",
+		Zones:        []string{"pek3a,pek3b"},
+		Count:        4,
+		IndexStart:   1,
+	}
+	ids := map[string]string{"img-a": "img-b", "vxnet-a": "vxnet-b"}
+	one := ric.forZone("pek3b", 2, 3, ids)
+	if one.zone != "pek3b" || one.Count != 2 || one.IndexStart != 3 || len(one.Zones) != 0 {
+		t.Errorf("got=%+v", one)
+	}
+	if one.ImageId != "img-b" || strings.Join(one.Vxnets, ",") != "vxnet-0,vxnet-b" || one.LoginKeyPair != "kp-a" {
+		t.Errorf("ids, got=%+v", one)
+	}
+	if ric.ImageId != "img-a" || ric.Vxnets[1] != "vxnet-a" {
+		t.Error("the original command should not be modified")
+	}
+	if got := strings.Join(splitZones([]string{"pek3a,pek3b", "pek3a", "sh1a"}), ","); got != "pek3a,pek3b,sh1a" {
+		t.Error("splitZones, got=", got)
+	}
+}
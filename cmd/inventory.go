@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ansibleGroupInvalid matches the characters which are not allowed in ansible group names.
+var ansibleGroupInvalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func addInventoryCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Render the instances as inventory, such as ssh config and hosts",
+	}
+
+	cmd.AddCommand(newCommand("ssh-config", "Render the Host entries of instances, update the managed block of --file or print it",
+		&sshConfigCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+	cmd.AddCommand(newCommand("hosts", "Render the hosts lines of instances, update the managed block of --file or print it",
+		&hostsCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+	cmd.AddCommand(newCommand("ansible", "Print the ansible dynamic inventory json of instances, --list or --host",
+		&ansibleInventoryCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+	cmd.AddCommand(newCommand("prometheus-sd", "Write the prometheus file_sd targets of instances, labeled by zone, instance id, name and tags",
+		&prometheusSdCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+	cmd.AddCommand(newCommand("exporter", "Serve /metrics of instance counts by zone, status and type, refreshed periodically",
+		&exporterCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*sshConfigCmd)(nil)
+var _ QingCloudCmd = (*hostsCmd)(nil)
+var _ QingCloudCmd = (*ansibleInventoryCmd)(nil)
+
+// inventoryInstances returns the instances of zone matched by selector, which are not terminated, sorted by name and id.
+// If zone is empty, the zone of flag or config is used.
+func inventoryInstances(zone, selectorStr string) ([]instanceItem, error) {
+	sel, err := parseSelector(selectorStr)
+	if err != nil {
+		return nil, err
+	}
+	items, err := describeAllInstances(&describeInstanceCmd{
+		instanceCmd: instanceCmd{
+			action: "DescribeInstances",
+			zone:   zone,
+		},
+		Verbose: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []instanceItem
+	for _, v := range items {
+		if v.Status != "terminated" && v.Status != "ceased" && sel.match(v.fields()) {
+			matched = append(matched, v)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].InstanceName != matched[j].InstanceName {
+			return matched[i].InstanceName < matched[j].InstanceName
+		}
+		return matched[i].InstanceId < matched[j].InstanceId
+	})
+	return matched, nil
+}
+
+// inventoryAddress returns the eip of instance, or the private ip if private is true or the instance has no eip.
+func inventoryAddress(item instanceItem, private bool) string {
+	if private || len(item.Eip.EipAddr) == 0 {
+		return item.privateIp()
+	}
+	return item.Eip.EipAddr
+}
+
+// inventoryAliases returns the instance name, spaces replaced by dash, and the instance id.
+func inventoryAliases(item instanceItem) []string {
+	name := strings.Join(strings.Fields(item.InstanceName), "-")
+	if len(name) == 0 || name == item.InstanceId {
+		return []string{item.InstanceId}
+	}
+	return []string{name, item.InstanceId}
+}
+
+// renderSshConfig renders a Host entry of every instance which has an address.
+func renderSshConfig(items []instanceItem, user string, private bool, keys map[string]string) (string, error) {
+	var b strings.Builder
+	for _, v := range items {
+		addr := inventoryAddress(v, private)
+		if len(addr) == 0 {
+			continue
+		}
+		u := user
+		if len(u) == 0 {
+			u = sshUser(v.Image.OsFamily)
+		}
+		identity, err := sshIdentity(v.KeyPairIds, keys)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&b, "Host %s\n", strings.Join(inventoryAliases(v), " "))
+		fmt.Fprintf(&b, "    HostName %s\n", addr)
+		fmt.Fprintf(&b, "    User %s\n", u)
+		if len(identity) != 0 {
+			fmt.Fprintf(&b, "    IdentityFile %s\n", identity)
+		}
+	}
+	return b.String(), nil
+}
+
+// renderHosts renders a hosts line of every instance which has an address.
+func renderHosts(items []instanceItem, private bool) string {
+	var b strings.Builder
+	for _, v := range items {
+		addr := inventoryAddress(v, private)
+		if len(addr) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s\t%s\n", addr, strings.Join(inventoryAliases(v), " "))
+	}
+	return b.String()
+}
+
+// updateManagedBlock replaces the block between the marker comments of content by block,
+// or appends the block with markers if content has no such block.
+func updateManagedBlock(content, marker, block string) string {
+	begin := "# BEGIN " + marker + " managed block\n"
+	end := "# END " + marker + " managed block\n"
+	managed := begin + block + end
+
+	if i := strings.Index(content, begin); i >= 0 {
+		if j := strings.Index(content[i:], end); j >= 0 {
+			return content[:i] + managed + content[i+j+len(end):]
+		}
+	}
+	if len(content) != 0 && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if len(content) != 0 {
+		content += "\n"
+	}
+	return content + managed
+}
+
+// writeManagedBlock updates the managed block of file, or prints the block if file is empty.
+func writeManagedBlock(file, marker, block string, perm os.FileMode) error {
+	if len(file) == 0 {
+		fmt.Print(block)
+		return nil
+	}
+	path, err := homedir.Expand(file)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	content := updateManagedBlock(string(data), marker, block)
+	if content == string(data) {
+		fmt.Println(path, "is up to date")
+		return nil
+	}
+	if err := ioutil.WriteFile(path, []byte(content), perm); err != nil {
+		return err
+	}
+	fmt.Println(path, "updated")
+	return nil
+}
+
+// inventoryMarker returns the marker of managed block, one block per zone.
+func inventoryMarker(zone string) string {
+	return "qingcloud-cli " + zone
+}
+
+type sshConfigCmd struct {
+	instanceCmd
+	Selector string `name:"selector" local:"1" usage:"only render matched instances, such as tag=prod,vxnet=vxnet-xxx. Keys are tag, name, status, type, vxnet"`
+	File     string `name:"file" local:"1" usage:"the ssh config file to update, such as ~/.ssh/config. Default, print the entries"`
+	User     string `name:"user" local:"1" usage:"the login user, default is guessed from the os family of image, such as ubuntu or root"`
+	Private  bool   `name:"private" local:"1" default:"false" usage:"use the private ip instead of the eip"`
+}
+
+func (scc *sshConfigCmd) Send() error {
+	scc.commonParam()
+	items, err := inventoryInstances("", scc.Selector)
+	if err != nil {
+		return err
+	}
+	block, err := renderSshConfig(items, scc.User, scc.Private, viper.GetStringMapString("ssh_keys"))
+	if err != nil {
+		return err
+	}
+	return writeManagedBlock(scc.File, inventoryMarker(scc.zone), block, 0600)
+}
+
+func (scc *sshConfigCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*scc), reflect.ValueOf(*scc), reflect.ValueOf(scc), cmd))
+}
+
+type hostsCmd struct {
+	instanceCmd
+	Selector string `name:"selector" local:"1" usage:"only render matched instances, such as tag=prod,vxnet=vxnet-xxx. Keys are tag, name, status, type, vxnet"`
+	File     string `name:"file" local:"1" usage:"the hosts file to update, such as /etc/hosts. Default, print the lines"`
+	Private  bool   `name:"private" local:"1" default:"false" usage:"use the private ip instead of the eip"`
+}
+
+func (hc *hostsCmd) Send() error {
+	hc.commonParam()
+	items, err := inventoryInstances("", hc.Selector)
+	if err != nil {
+		return err
+	}
+	return writeManagedBlock(hc.File, inventoryMarker(hc.zone), renderHosts(items, hc.Private), 0644)
+}
+
+func (hc *hostsCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*hc), reflect.ValueOf(*hc), reflect.ValueOf(hc), cmd))
+}
+
+// ansibleGroup returns the ansible group name of prefix and value, invalid characters are replaced by underscore.
+func ansibleGroup(prefix, value string) string {
+	return prefix + "_" + ansibleGroupInvalid.ReplaceAllString(value, "_")
+}
+
+// ansibleHostVars returns the hostvars of instance, the connection variables and the instance attributes.
+func ansibleHostVars(item instanceItem, zone, user string, private bool, keys map[string]string) (map[string]interface{}, error) {
+	if len(user) == 0 {
+		user = sshUser(item.Image.OsFamily)
+	}
+	vars := map[string]interface{}{
+		"ansible_host":            inventoryAddress(item, private),
+		"ansible_user":            user,
+		"qingcloud_zone":          zone,
+		"qingcloud_instance_id":   item.InstanceId,
+		"qingcloud_instance_name": item.InstanceName,
+		"qingcloud_instance_type": item.InstanceType,
+		"qingcloud_status":        item.Status,
+		"qingcloud_cpu":           item.VcpusCurrent,
+		"qingcloud_memory":        item.MemoryCurrent,
+		"qingcloud_image_id":      item.Image.ImageId,
+		"qingcloud_os_family":     item.Image.OsFamily,
+		"qingcloud_private_ip":    item.privateIp(),
+		"qingcloud_eip":           item.Eip.EipAddr,
+	}
+	identity, err := sshIdentity(item.KeyPairIds, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(identity) != 0 {
+		vars["ansible_ssh_private_key_file"] = identity
+	}
+	tags := []string{}
+	for _, t := range item.Tags {
+		tags = append(tags, t.TagName)
+	}
+	vars["qingcloud_tags"] = tags
+	vxnets := []string{}
+	for _, v := range item.Vxnets {
+		vxnets = append(vxnets, v.VxnetId)
+	}
+	vars["qingcloud_vxnets"] = vxnets
+	return vars, nil
+}
+
+// ansibleGroups returns the groups of instance, by zone, instance type, status, tags and vxnets.
+func ansibleGroups(item instanceItem, zone string) []string {
+	groups := []string{ansibleGroup("zone", zone), ansibleGroup("status", item.Status)}
+	if len(item.InstanceType) != 0 {
+		groups = append(groups, ansibleGroup("type", item.InstanceType))
+	}
+	for _, t := range item.Tags {
+		groups = append(groups, ansibleGroup("tag", t.TagName))
+	}
+	for _, v := range item.Vxnets {
+		groups = append(groups, ansibleGroup("vxnet", v.VxnetId))
+	}
+	return groups
+}
+
+// ansibleInventory returns the --list output of ansible dynamic inventory, instances are named by instance id.
+func ansibleInventory(items []instanceItem, zone, user string, private bool, keys map[string]string) (map[string]interface{}, error) {
+	inventory := make(map[string]interface{})
+	groups := make(map[string][]string)
+	hostVars := make(map[string]interface{})
+	for _, v := range items {
+		vars, err := ansibleHostVars(v, zone, user, private, keys)
+		if err != nil {
+			return nil, err
+		}
+		hostVars[v.InstanceId] = vars
+		for _, g := range ansibleGroups(v, zone) {
+			groups[g] = append(groups[g], v.InstanceId)
+		}
+	}
+
+	var children []string
+	for g, hosts := range groups {
+		inventory[g] = map[string]interface{}{"hosts": hosts}
+		children = append(children, g)
+	}
+	sort.Strings(children)
+	inventory["all"] = map[string]interface{}{"children": children}
+	inventory["_meta"] = map[string]interface{}{"hostvars": hostVars}
+	return inventory, nil
+}
+
+type ansibleInventoryCmd struct {
+	instanceCmd
+	List     bool   `name:"list" local:"1" default:"false" usage:"print all groups and hostvars, the default"`
+	Host     string `name:"host" local:"1" usage:"print the hostvars of the instance id"`
+	Selector string `name:"selector" local:"1" usage:"only render matched instances, such as tag=prod,vxnet=vxnet-xxx. Keys are tag, name, status, type, vxnet"`
+	User     string `name:"user" local:"1" usage:"the ansible user, default is guessed from the os family of image, such as ubuntu or root"`
+	Private  bool   `name:"private" local:"1" default:"false" usage:"use the private ip instead of the eip as ansible_host"`
+}
+
+func (aic *ansibleInventoryCmd) Send() error {
+	aic.commonParam()
+	items, err := inventoryInstances("", aic.Selector)
+	if err != nil {
+		return err
+	}
+
+	var out interface{}
+	keys := viper.GetStringMapString("ssh_keys")
+	if len(aic.Host) != 0 {
+		//ansible expects an empty object for unknown hosts
+		out = map[string]interface{}{}
+		for _, v := range items {
+			if v.InstanceId == aic.Host {
+				if out, err = ansibleHostVars(v, aic.zone, aic.User, aic.Private, keys); err != nil {
+					return err
+				}
+			}
+		}
+	} else if out, err = ansibleInventory(items, aic.zone, aic.User, aic.Private, keys); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func (aic *ansibleInventoryCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*aic), reflect.ValueOf(*aic), reflect.ValueOf(aic), cmd))
+
+	//for completion
+	registerInstanceIdCompletion(cmd, "host")
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateManagedBlock(t *testing.T) {
+	content := "Host bastion\n    HostName 1.1.1.1"
+	first := updateManagedBlock(content, "qingcloud-cli pek3", "Host web\n")
+	expected := "Host bastion\n    HostName 1.1.1.1\n\n" +
+		"# BEGIN qingcloud-cli pek3 managed block\nHost web\n# END qingcloud-cli pek3 managed block\n"
+	if first != expected {
+		t.Fatalf("append, got=%q, expected=%q", first, expected)
+	}
+
+	if again := updateManagedBlock(first, "qingcloud-cli pek3", "Host web\n"); again != first {
+		t.Errorf("not idempotent, got=%q", again)
+	}
+
+	replaced := updateManagedBlock(first+"Host other\n", "qingcloud-cli pek3", "Host db\n")
+	expected = "Host bastion\n    HostName 1.1.1.1\n\n" +
+		"# BEGIN qingcloud-cli pek3 managed block\nHost db\n# END qingcloud-cli pek3 managed block\nHost other\n"
+	if replaced != expected {
+		t.Errorf("replace, got=%q, expected=%q", replaced, expected)
+	}
+}
+
+func TestRenderInventory(t *testing.T) {
+	web := instanceItem{InstanceId: "i-1", InstanceName: "web 1", KeyPairIds: []string{"kp-1"}}
+	web.Eip.EipAddr = "1.2.3.4"
+	web.Image.OsFamily = "ubuntu"
+	db := instanceItem{InstanceId: "i-2", Vxnets: []instanceVxnet{{VxnetId: "vxnet-1", PrivateIp: "192.168.0.2"}}}
+	none := instanceItem{InstanceId: "i-3"}
+	items := []instanceItem{web, db, none}
+
+	hosts := renderHosts(items, false)
+	expected := "1.2.3.4\tweb-1 i-1\n192.168.0.2\ti-2\n"
+	if hosts != expected {
+		t.Errorf("hosts, got=%q, expected=%q", hosts, expected)
+	}
+
+	config, err := renderSshConfig(items[:1], "", false, map[string]string{"kp-1": "/keys/kp1"})
+	expected = "Host web-1 i-1\n    HostName 1.2.3.4\n    User ubuntu\n    IdentityFile /keys/kp1\n"
+	if err != nil || config != expected {
+		t.Errorf("ssh config, got=%q, expected=%q, err=%v", config, expected, err)
+	}
+}
+
+func TestAnsibleInventory(t *testing.T) {
+	web := instanceItem{InstanceId: "i-1", InstanceType: "c2m4", Status: "running",
+		Tags: []tagItem{{TagId: "tag-1", TagName: "owner=alice"}}, Vxnets: []instanceVxnet{{VxnetId: "vxnet-1", PrivateIp: "192.168.0.2"}}}
+	db := instanceItem{InstanceId: "i-2", InstanceType: "c4m8", Status: "stopped"}
+
+	inventory, err := ansibleInventory([]instanceItem{web, db}, "pek3a", "", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"zone_pek3a":      {"i-1", "i-2"},
+		"status_running":  {"i-1"},
+		"status_stopped":  {"i-2"},
+		"type_c2m4":       {"i-1"},
+		"type_c4m8":       {"i-2"},
+		"tag_owner_alice": {"i-1"},
+		"vxnet_vxnet_1":   {"i-1"},
+	}
+	for g, hosts := range expected {
+		group, ok := inventory[g].(map[string]interface{})
+		if !ok {
+			t.Errorf("group %s not found", g)
+			continue
+		}
+		if got := group["hosts"].([]string); strings.Join(got, ",") != strings.Join(hosts, ",") {
+			t.Errorf("group %s, got=%v, expected=%v", g, got, hosts)
+		}
+	}
+	if children := inventory["all"].(map[string]interface{})["children"].([]string); len(children) != len(expected) {
+		t.Errorf("all children, got=%v", children)
+	}
+
+	hostVars := inventory["_meta"].(map[string]interface{})["hostvars"].(map[string]interface{})
+	vars := hostVars["i-1"].(map[string]interface{})
+	if vars["ansible_host"] != "192.168.0.2" || vars["ansible_user"] != "root" {
+		t.Errorf("hostvars, got=%v", vars)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+const jobPollInterval = 3 * time.Second
+const jobWaitTimeout = 10 * time.Minute
+
+// describeJobCmd is only used internally to poll the status of asynchronous jobs.
+type describeJobCmd struct {
+	instanceCmd
+	Jobs []string `name:"jobs"`
+}
+
+// waitJob polls DescribeJobs until the job succeeds, fails or the timeout reached.
+func waitJob(jobId string) error {
+	return waitZoneJob("", jobId)
+}
+
+// waitZoneJob waits the job of zone, empty zone is the current zone.
+func waitZoneJob(zone, jobId string) error {
+	type response struct {
+		JobSet []struct {
+			JobId  string `json:"job_id"`
+			Status string `json:"status"`
+		} `json:"job_set"`
+	}
+
+	deadline := time.Now().Add(jobWaitTimeout)
+	for time.Now().Before(deadline) {
+		param := &describeJobCmd{
+			instanceCmd: instanceCmd{
+				action: "DescribeJobs",
+				zone:   zone,
+			},
+			Jobs: []string{jobId},
+		}
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return err
+		}
+		if len(resp.JobSet) == 0 {
+			return fmt.Errorf("job %s not found", jobId)
+		}
+
+		switch resp.JobSet[0].Status {
+		case "successful":
+			return nil
+		case "failed", "done with failure":
+			return fmt.Errorf("job %s %s", jobId, resp.JobSet[0].Status)
+		}
+		time.Sleep(jobPollInterval)
+	}
+	return fmt.Errorf("wait job %s timeout", jobId)
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+var validEncryptMethod = []string{"ssh-rsa", "ssh-dss"}
+var validPublicKeyType = []string{"ssh-rsa", "ssh-dss", "ssh-ed25519", "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521"}
+
+func addKeyPairCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "keypairs",
+		Short: "Manage SSH keypairs, describe, create, import, delete keypair and attach or detach instances",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch keypair list, filter by keypair id, instance etc.",
+		&describeKeyPairCmd{instanceCmd: instanceCmd{action: "DescribeKeyPairs"}}))
+	cmd.AddCommand(newCommand("create", "Create a keypair generated by system, or with the given public key",
+		&createKeyPairCmd{instanceCmd: instanceCmd{action: "CreateKeyPair"}}))
+	cmd.AddCommand(newCommand("import", "Create a keypair with a local SSH public key file",
+		&importKeyPairCmd{}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many keypairs which given keypair id",
+		&deleteKeyPairCmd{instanceCmd: instanceCmd{action: "DeleteKeyPairs"}}))
+	cmd.AddCommand(newCommand("attach", "Attach one or many keypairs to instances",
+		&attachKeyPairCmd{instanceCmd: instanceCmd{action: "AttachKeyPairs"}}))
+	cmd.AddCommand(newCommand("detach", "Detach one or many keypairs from instances",
+		&detachKeyPairCmd{instanceCmd: instanceCmd{action: "DetachKeyPairs"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeKeyPairCmd)(nil)
+var _ QingCloudCmd = (*createKeyPairCmd)(nil)
+var _ QingCloudCmd = (*importKeyPairCmd)(nil)
+var _ QingCloudCmd = (*deleteKeyPairCmd)(nil)
+var _ QingCloudCmd = (*attachKeyPairCmd)(nil)
+var _ QingCloudCmd = (*detachKeyPairCmd)(nil)
+
+// keyPairItem is one element of keypair_set in DescribeKeyPairs response.
+type keyPairItem struct {
+	KeyPairId     string `json:"keypair_id"`
+	KeyPairName   string `json:"keypair_name"`
+	EncryptMethod string `json:"encrypt_method"`
+}
+
+func describeKeyPairs(param *describeKeyPairCmd) ([]keyPairItem, error) {
+	type response struct {
+		KeyPairSet []keyPairItem `json:"keypair_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.KeyPairSet, nil
+}
+
+// registerKeyPairIdCompletion completes flagName with the keypair ids, described by name.
+func registerKeyPairIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "keypairs", func() ([]string, error) {
+		var tmp []string
+		items, err := describeKeyPairs(&describeKeyPairCmd{
+			instanceCmd: completionInstanceCmd("DescribeKeyPairs"),
+			Limit:       100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range items {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s (%s)", v.KeyPairId, v.KeyPairName, v.EncryptMethod))
+		}
+		return tmp, nil
+	})
+}
+
+// parsePublicKey validates an authorized_keys format public key, returns the key type, the key and the comment.
+func parsePublicKey(data []byte) (string, string, string, error) {
+	fields := strings.Fields(string(bytes.TrimSpace(data)))
+	if len(fields) < 2 {
+		return "", "", "", errors.New("invalid public key, expected format is: <type> <base64 key> [comment]")
+	}
+	keyType, key := fields[0], fields[1]
+	if !validParam(validPublicKeyType, keyType) {
+		return "", "", "", fmt.Errorf("unsupported public key type %s, must be one of %v", keyType, validPublicKeyType)
+	}
+
+	//the key blob starts with the length prefixed key type
+	blob, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid public key, %v", err)
+	}
+	if len(blob) < 4 {
+		return "", "", "", errors.New("invalid public key, key is too short")
+	}
+	n := binary.BigEndian.Uint32(blob)
+	if uint64(len(blob)) < 4+uint64(n) || string(blob[4:4+n]) != keyType {
+		return "", "", "", errors.New("invalid public key, key type mismatch")
+	}
+	return keyType, key, strings.Join(fields[2:], " "), nil
+}
+
+type describeKeyPairCmd struct {
+	instanceCmd
+	KeyPairIds    []string `name:"keypairs" usage:"keypair id[s] which want to fetch. Multiple keypairs set like --keypairs kp1 --keypairs kp2"`
+	InstanceId    string   `name:"instance_id" usage:"filter by the instance which keypair attached to"`
+	EncryptMethod string   `name:"encrypt_method" usage:"filter by encrypt method, ssh-rsa or ssh-dss"`
+	SearchWord    string   `name:"search_word" usage:"search keyword, keypair id, name are supported"`
+	Tags          []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Verbose       bool     `name:"verbose" default:"false" usage:"show the instances of keypair or not"`
+	Offset        int64    `name:"offset" default:"0" usage:"matched keypair offset"`
+	Limit         int64    `name:"limit" default:"20" usage:"matched keypair limit, default is 20, max is 100"`
+}
+
+func (dkc *describeKeyPairCmd) Send() error {
+	if dkc.Limit < 20 || dkc.Limit > 100 {
+		dkc.Limit = 20
+	}
+	return dkc.send(dkc)
+}
+
+func (dkc *describeKeyPairCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dkc), reflect.ValueOf(*dkc), reflect.ValueOf(dkc), cmd))
+
+	//for completion
+	registerKeyPairIdCompletion(cmd, "keypairs")
+	registerInstanceIdCompletion(cmd, "instance_id")
+
+	flagName := "encrypt_method"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validEncryptMethod, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type createKeyPairCmd struct {
+	instanceCmd
+	KeyPairName   string `name:"keypair_name" usage:"the keypair name"`
+	Mode          string `name:"mode" default:"system" usage:"system: generate the keypair by system, user: use the given public key"`
+	EncryptMethod string `name:"encrypt_method" default:"ssh-rsa" usage:"encrypt method, ssh-rsa or ssh-dss, valid when mode is system"`
+	PublicKey     string `name:"public_key" usage:"the public key, required when mode is user"`
+}
+
+func (ckc *createKeyPairCmd) Send() error {
+	if ckc.Mode != "system" && ckc.Mode != "user" {
+		fmt.Println("mode must be system or user")
+		os.Exit(0)
+	}
+	if ckc.Mode == "system" && !validParam(validEncryptMethod, ckc.EncryptMethod) {
+		fmt.Println("encrypt method is invalid, must be one of", validEncryptMethod)
+		os.Exit(0)
+	}
+	if ckc.Mode == "user" && len(ckc.PublicKey) == 0 {
+		fmt.Println("public key is required when mode is user")
+		os.Exit(0)
+	}
+	return ckc.send(ckc)
+}
+
+func (ckc *createKeyPairCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ckc), reflect.ValueOf(*ckc), reflect.ValueOf(ckc), cmd))
+
+	//for completion
+	flagName := "mode"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"system", "user"}, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "encrypt_method"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validEncryptMethod, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type importKeyPairCmd struct {
+	instanceCmd
+	KeyPairName   string `name:"keypair_name" local:"1" usage:"the keypair name, default is the comment of public key or the file name"`
+	PublicKeyFile string `name:"public-key-file" local:"1" required:"1" usage:"the local SSH public key file, such as ~/.ssh/id_ed25519.pub"`
+}
+
+func (ikc *importKeyPairCmd) Send() error {
+	path, err := homedir.Expand(ikc.PublicKeyFile)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	keyType, key, comment, err := parsePublicKey(data)
+	if err != nil {
+		return err
+	}
+
+	param := &createKeyPairCmd{
+		instanceCmd: instanceCmd{
+			action: "CreateKeyPair",
+		},
+		KeyPairName: ikc.KeyPairName,
+		Mode:        "user",
+		PublicKey:   keyType + " " + key,
+	}
+	if len(param.KeyPairName) == 0 {
+		param.KeyPairName = comment
+	}
+	if len(param.KeyPairName) == 0 {
+		param.KeyPairName = strings.TrimSuffix(filepath.Base(path), ".pub")
+	}
+	return param.send(param)
+}
+
+func (ikc *importKeyPairCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ikc), reflect.ValueOf(*ikc), reflect.ValueOf(ikc), cmd))
+
+	//for completion
+	flagName := "public-key-file"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"pub"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+}
+
+type deleteKeyPairCmd struct {
+	instanceCmd
+	KeyPairIds []string `name:"keypairs" required:"1" usage:"keypair id[s] which want to delete. Multiple keypairs, --keypairs kp1 --keypairs kp2"`
+}
+
+func (dkc *deleteKeyPairCmd) Send() error {
+	return dkc.send(dkc)
+}
+
+func (dkc *deleteKeyPairCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dkc), reflect.ValueOf(*dkc), reflect.ValueOf(dkc), cmd))
+
+	//for completion
+	registerKeyPairIdCompletion(cmd, "keypairs")
+}
+
+type attachKeyPairCmd struct {
+	instanceCmd
+	KeyPairIds  []string `name:"keypairs" required:"1" usage:"keypair id[s] which want to attach. Multiple keypairs, --keypairs kp1 --keypairs kp2"`
+	InstanceIds []string `name:"instances" required:"1" usage:"instance id[s] which keypairs attach to. Multiple instances, --instances ins1 --instances ins2"`
+}
+
+func (akc *attachKeyPairCmd) Send() error {
+	return akc.send(akc)
+}
+
+func (akc *attachKeyPairCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*akc), reflect.ValueOf(*akc), reflect.ValueOf(akc), cmd))
+
+	//for completion
+	registerKeyPairIdCompletion(cmd, "keypairs")
+	registerInstanceIdCompletion(cmd, "instances")
+}
+
+type detachKeyPairCmd struct {
+	instanceCmd
+	KeyPairIds  []string `name:"keypairs" required:"1" usage:"keypair id[s] which want to detach. Multiple keypairs, --keypairs kp1 --keypairs kp2"`
+	InstanceIds []string `name:"instances" required:"1" usage:"instance id[s] which keypairs detach from. Multiple instances, --instances ins1 --instances ins2"`
+}
+
+func (dkc *detachKeyPairCmd) Send() error {
+	return dkc.send(dkc)
+}
+
+func (dkc *detachKeyPairCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dkc), reflect.ValueOf(*dkc), reflect.ValueOf(dkc), cmd))
+
+	//for completion
+	registerKeyPairIdCompletion(cmd, "keypairs")
+	registerInstanceIdCompletion(cmd, "instances")
+}
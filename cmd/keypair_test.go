@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	key := "AAAAC3NzaC1lZDI1NTE5AAAAIGb3Zq1n5nV0xq3e5wY1oQk1v0m8h8m9XjYl3oQwqJ9x"
+	keyType, got, comment, err := parsePublicKey([]byte("ssh-ed25519 " + key + " dev@laptop\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyType != "ssh-ed25519" || got != key || comment != "dev@laptop" {
+		t.Error("got=", keyType, got, comment)
+	}
+
+	invalid := []string{
+		"",
+		"ssh-ed25519",
+		"ssh-foo " + key,
+		"ssh-rsa " + key,
+		"ssh-ed25519 not-base64!",
+		"ssh-ed25519 AAAA",
+	}
+	for _, v := range invalid {
+		if _, _, _, err := parsePublicKey([]byte(v)); err == nil {
+			t.Error("should be invalid:", v)
+		}
+	}
+}
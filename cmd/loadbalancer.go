@@ -0,0 +1,673 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+var validLoadBalancerType = []string{"0", "1", "2", "3", "4", "5"}
+var validListenerProtocol = []string{"http", "https", "tcp"}
+var validBalanceMode = []string{"roundrobin", "leastconn", "source"}
+
+func addLoadBalancerCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "loadbalancers",
+		Short: "Manage load balancers, listeners, backends and server certificates",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch load balancer list, filter by load balancer id, status etc.",
+		&describeLoadBalancerCmd{instanceCmd: instanceCmd{action: "DescribeLoadBalancers"}}))
+	cmd.AddCommand(newCommand("create", "Create a load balancer",
+		&createLoadBalancerCmd{instanceCmd: instanceCmd{action: "CreateLoadBalancer"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many load balancers which given load balancer id",
+		&deleteLoadBalancerCmd{instanceCmd: instanceCmd{action: "DeleteLoadBalancers"}}))
+	cmd.AddCommand(newCommand("update", "Apply the modified listeners and backends of one or many load balancers",
+		&updateLoadBalancerCmd{instanceCmd: instanceCmd{action: "UpdateLoadBalancers"}}))
+	cmd.AddCommand(newCommand("drain", "Set the weight of all backends of an instance to 0 before maintenance",
+		&drainLoadBalancerBackendCmd{}))
+
+	listenerCmd := &cobra.Command{
+		Use:   "listeners",
+		Short: "Manage the listeners of load balancer",
+	}
+	listenerCmd.AddCommand(newCommand("describe", "Fetch the listeners of load balancer",
+		&describeListenerCmd{instanceCmd: instanceCmd{action: "DescribeLoadBalancerListeners"}}))
+	listenerCmd.AddCommand(newCommand("add", "Add a http, https or tcp listener to load balancer",
+		&addListenerCmd{instanceCmd: instanceCmd{action: "AddLoadBalancerListeners"}}))
+	listenerCmd.AddCommand(newCommand("delete", "Delete one or many listeners which given listener id",
+		&deleteListenerCmd{instanceCmd: instanceCmd{action: "DeleteLoadBalancerListeners"}}))
+	cmd.AddCommand(listenerCmd)
+
+	backendCmd := &cobra.Command{
+		Use:   "backends",
+		Short: "Manage the backends of load balancer listener",
+	}
+	backendCmd.AddCommand(newCommand("describe", "Fetch the backends of load balancer or listener",
+		&describeBackendCmd{instanceCmd: instanceCmd{action: "DescribeLoadBalancerBackends"}}))
+	backendCmd.AddCommand(newCommand("add", "Add one or many instances as backends of listener",
+		&addBackendCmd{instanceCmd: instanceCmd{action: "AddLoadBalancerBackends"}}))
+	backendCmd.AddCommand(newCommand("delete", "Remove one or many backends which given backend id",
+		&deleteBackendCmd{instanceCmd: instanceCmd{action: "DeleteLoadBalancerBackends"}}))
+	backendCmd.AddCommand(newCommand("modify", "Modify the port, weight and name of backend",
+		&modifyBackendCmd{instanceCmd: instanceCmd{action: "ModifyLoadBalancerBackendAttributes"}}))
+	cmd.AddCommand(backendCmd)
+
+	certificateCmd := &cobra.Command{
+		Use:   "certificates",
+		Short: "Manage the server certificates used by https listener",
+	}
+	certificateCmd.AddCommand(newCommand("describe", "Fetch server certificate list",
+		&describeServerCertificateCmd{instanceCmd: instanceCmd{action: "DescribeServerCertificates"}}))
+	//the private key is sent by POST, never in the url which may be logged
+	certificateCmd.AddCommand(newCommand("create", "Create a server certificate with the certificate and private key, by content or local files",
+		&createServerCertificateCmd{instanceCmd: instanceCmd{action: "CreateServerCertificate", post: true}}))
+	certificateCmd.AddCommand(newCommand("delete", "Delete one or many server certificates which given certificate id",
+		&deleteServerCertificateCmd{instanceCmd: instanceCmd{action: "DeleteServerCertificates"}}))
+	cmd.AddCommand(certificateCmd)
+
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeLoadBalancerCmd)(nil)
+var _ QingCloudCmd = (*createLoadBalancerCmd)(nil)
+var _ QingCloudCmd = (*deleteLoadBalancerCmd)(nil)
+var _ QingCloudCmd = (*updateLoadBalancerCmd)(nil)
+var _ QingCloudCmd = (*drainLoadBalancerBackendCmd)(nil)
+var _ QingCloudCmd = (*describeListenerCmd)(nil)
+var _ QingCloudCmd = (*addListenerCmd)(nil)
+var _ QingCloudCmd = (*deleteListenerCmd)(nil)
+var _ QingCloudCmd = (*describeBackendCmd)(nil)
+var _ QingCloudCmd = (*addBackendCmd)(nil)
+var _ QingCloudCmd = (*deleteBackendCmd)(nil)
+var _ QingCloudCmd = (*modifyBackendCmd)(nil)
+var _ QingCloudCmd = (*describeServerCertificateCmd)(nil)
+var _ QingCloudCmd = (*createServerCertificateCmd)(nil)
+var _ QingCloudCmd = (*deleteServerCertificateCmd)(nil)
+
+// loadBalancerItem is one element of loadbalancer_set in DescribeLoadBalancers response.
+type loadBalancerItem struct {
+	LoadBalancerId   string `json:"loadbalancer_id"`
+	LoadBalancerName string `json:"loadbalancer_name"`
+	Status           string `json:"status"`
+}
+
+// registerLoadBalancerIdCompletion completes flagName with the load balancer ids, described by name and status.
+func registerLoadBalancerIdCompletion(cmd *cobra.Command, flagName string) {
+	type response struct {
+		LoadBalancerSet []loadBalancerItem `json:"loadbalancer_set"`
+	}
+
+	registerCachedCompletion(cmd, flagName, "loadbalancers", func() ([]string, error) {
+		var tmp []string
+		param := &describeLoadBalancerCmd{
+			instanceCmd: completionInstanceCmd("DescribeLoadBalancers"),
+			Limit:       100,
+		}
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		for _, v := range resp.LoadBalancerSet {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s (%s)", v.LoadBalancerId, v.LoadBalancerName, v.Status))
+		}
+		return tmp, nil
+	})
+}
+
+// listenerItem is one element of loadbalancer_listener_set in DescribeLoadBalancerListeners response.
+type listenerItem struct {
+	LoadBalancerListenerId   string `json:"loadbalancer_listener_id"`
+	LoadBalancerListenerName string `json:"loadbalancer_listener_name"`
+	ListenerProtocol         string `json:"listener_protocol"`
+	ListenerPort             int64  `json:"listener_port"`
+}
+
+// registerListenerIdCompletion completes flagName with the listener ids, described by name, protocol and port.
+func registerListenerIdCompletion(cmd *cobra.Command, flagName string) {
+	type response struct {
+		LoadBalancerListenerSet []listenerItem `json:"loadbalancer_listener_set"`
+	}
+
+	registerCachedCompletion(cmd, flagName, "loadbalancer_listeners", func() ([]string, error) {
+		var tmp []string
+		param := &describeListenerCmd{
+			instanceCmd: completionInstanceCmd("DescribeLoadBalancerListeners"),
+			Limit:       100,
+		}
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		for _, v := range resp.LoadBalancerListenerSet {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s %s:%d", v.LoadBalancerListenerId, v.LoadBalancerListenerName, v.ListenerProtocol, v.ListenerPort))
+		}
+		return tmp, nil
+	})
+}
+
+// backendItem is one element of loadbalancer_backend_set in DescribeLoadBalancerBackends response.
+type backendItem struct {
+	LoadBalancerBackendId   string `json:"loadbalancer_backend_id"`
+	LoadBalancerBackendName string `json:"loadbalancer_backend_name"`
+	LoadBalancerId          string `json:"loadbalancer_id"`
+	LoadBalancerListenerId  string `json:"loadbalancer_listener_id"`
+	ResourceId              string `json:"resource_id"`
+	Port                    int64  `json:"port"`
+	Weight                  int64  `json:"weight"`
+}
+
+// describeAllBackends fetches all backends matched param page by page.
+func describeAllBackends(param *describeBackendCmd) ([]backendItem, error) {
+	type response struct {
+		LoadBalancerBackendSet []backendItem `json:"loadbalancer_backend_set"`
+		TotalCount             int64         `json:"total_count"`
+	}
+
+	var items []backendItem
+	for {
+		param.Offset = int64(len(items))
+		param.Limit = 100
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		items = append(items, resp.LoadBalancerBackendSet...)
+		if len(resp.LoadBalancerBackendSet) == 0 || int64(len(items)) >= resp.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+type describeLoadBalancerCmd struct {
+	instanceCmd
+	LoadBalancerIds []string `name:"loadbalancers" usage:"load balancer id[s] which want to fetch. Multiple load balancers set like --loadbalancers lb1 --loadbalancers lb2"`
+	Status          []string `name:"status" usage:"load balancer status[es] which want to fetch. Multiple status --status st1 --status st2"`
+	SearchWord      string   `name:"search_word" usage:"search keyword, load balancer id, name are supported"`
+	Tags            []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Verbose         bool     `name:"verbose" default:"false" usage:"show the listeners of load balancer or not"`
+	Offset          int64    `name:"offset" default:"0" usage:"matched load balancer offset"`
+	Limit           int64    `name:"limit" default:"20" usage:"matched load balancer limit, default is 20, max is 100"`
+}
+
+func (dlc *describeLoadBalancerCmd) Send() error {
+	if dlc.Limit < 20 || dlc.Limit > 100 {
+		dlc.Limit = 20
+	}
+	return dlc.send(dlc)
+}
+
+func (dlc *describeLoadBalancerCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dlc), reflect.ValueOf(*dlc), reflect.ValueOf(dlc), cmd))
+
+	//for completion
+	registerLoadBalancerIdCompletion(cmd, "loadbalancers")
+}
+
+type createLoadBalancerCmd struct {
+	instanceCmd
+	LoadBalancerName string   `name:"loadbalancer_name" usage:"the load balancer name"`
+	LoadBalancerType string   `name:"loadbalancer_type" default:"0" usage:"load balancer type, the max connections of 0-5 are 5k, 20k, 40k, 100k, 200k, 500k"`
+	Eips             []string `name:"eips" usage:"the eip id[s] for public load balancer. Multiple eips, --eips eip1 --eips eip2"`
+	VxnetId          string   `name:"vxnet" usage:"the vxnet id for private load balancer"`
+	PrivateIp        string   `name:"private_ip" usage:"the private ip in vxnet for private load balancer"`
+	SecurityGroup    string   `name:"security_group" usage:"security group which load balancer uses"`
+	NodeCount        int64    `name:"node_count" usage:"the count of load balancer nodes"`
+}
+
+func (clc *createLoadBalancerCmd) Send() error {
+	if !validParam(validLoadBalancerType, clc.LoadBalancerType) {
+		fmt.Println("load balancer type is invalid, must be one of", validLoadBalancerType)
+		os.Exit(0)
+	}
+	if len(clc.Eips) == 0 && len(clc.VxnetId) == 0 {
+		fmt.Println("either eips or vxnet must be specified")
+		os.Exit(0)
+	}
+	return clc.send(clc)
+}
+
+func (clc *createLoadBalancerCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*clc), reflect.ValueOf(*clc), reflect.ValueOf(clc), cmd))
+
+	//for completion
+	registerEipIdCompletion(cmd, "eips", "available")
+	registerVxnetIdCompletion(cmd, "vxnet")
+	registerSecurityGroupIdCompletion(cmd, "security_group")
+
+	flagName := "loadbalancer_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validLoadBalancerType, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type deleteLoadBalancerCmd struct {
+	instanceCmd
+	LoadBalancerIds []string `name:"loadbalancers" required:"1" usage:"load balancer id[s] which want to delete. Multiple load balancers, --loadbalancers lb1 --loadbalancers lb2"`
+}
+
+func (dlc *deleteLoadBalancerCmd) Send() error {
+	return dlc.send(dlc)
+}
+
+func (dlc *deleteLoadBalancerCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dlc), reflect.ValueOf(*dlc), reflect.ValueOf(dlc), cmd))
+
+	//for completion
+	registerLoadBalancerIdCompletion(cmd, "loadbalancers")
+}
+
+type updateLoadBalancerCmd struct {
+	instanceCmd
+	LoadBalancerIds []string `name:"loadbalancers" required:"1" usage:"load balancer id[s] which want to update. Multiple load balancers, --loadbalancers lb1 --loadbalancers lb2"`
+}
+
+func (ulc *updateLoadBalancerCmd) Send() error {
+	return ulc.send(ulc)
+}
+
+func (ulc *updateLoadBalancerCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ulc), reflect.ValueOf(*ulc), reflect.ValueOf(ulc), cmd))
+
+	//for completion
+	registerLoadBalancerIdCompletion(cmd, "loadbalancers")
+}
+
+type describeListenerCmd struct {
+	instanceCmd
+	LoadBalancerListenerIds []string `name:"loadbalancer_listeners" usage:"listener id[s] which want to fetch. Multiple listeners set like --loadbalancer_listeners lbl1 --loadbalancer_listeners lbl2"`
+	LoadBalancerId          string   `name:"loadbalancer" usage:"filter by the load balancer which listeners belong to"`
+	Verbose                 bool     `name:"verbose" default:"false" usage:"show the backends of listener or not"`
+	Offset                  int64    `name:"offset" default:"0" usage:"matched listener offset"`
+	Limit                   int64    `name:"limit" default:"20" usage:"matched listener limit, default is 20, max is 100"`
+}
+
+func (dlc *describeListenerCmd) Send() error {
+	if dlc.Limit < 20 || dlc.Limit > 100 {
+		dlc.Limit = 20
+	}
+	return dlc.send(dlc)
+}
+
+func (dlc *describeListenerCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dlc), reflect.ValueOf(*dlc), reflect.ValueOf(dlc), cmd))
+
+	//for completion
+	registerListenerIdCompletion(cmd, "loadbalancer_listeners")
+	registerLoadBalancerIdCompletion(cmd, "loadbalancer")
+}
+
+// listener is one element of the listeners parameter in AddLoadBalancerListeners.
+type listener struct {
+	ListenerName        string `name:"loadbalancer_listener_name"`
+	ListenerPort        int64  `name:"listener_port"`
+	ListenerProtocol    string `name:"listener_protocol"`
+	BackendProtocol     string `name:"backend_protocol"`
+	ServerCertificateId string `name:"server_certificate_id"`
+	BalanceMode         string `name:"balance_mode"`
+	SessionSticky       string `name:"session_sticky"`
+	Forwardfor          int64  `name:"forwardfor"`
+	HealthyCheckMethod  string `name:"healthy_check_method"`
+	HealthyCheckOption  string `name:"healthy_check_option"`
+	Timeout             int64  `name:"timeout"`
+}
+
+type addListenerCmd struct {
+	instanceCmd
+	LoadBalancerId      string     `name:"loadbalancer" required:"1" usage:"the load balancer id which listener add to"`
+	Listeners           []listener `name:"listeners"`
+	ListenerName        string     `name:"listener_name" local:"1" usage:"the listener name"`
+	ListenerPort        int64      `name:"listener_port" local:"1" required:"1" usage:"the port which listener listens on"`
+	ListenerProtocol    string     `name:"listener_protocol" local:"1" required:"1" usage:"the listener protocol, http, https or tcp"`
+	BackendProtocol     string     `name:"backend_protocol" local:"1" usage:"the backend protocol, http or tcp, default is same as listener protocol, http for https"`
+	ServerCertificateId string     `name:"server_certificate_id" local:"1" usage:"the server certificate id, required when protocol is https"`
+	BalanceMode         string     `name:"balance_mode" local:"1" default:"roundrobin" usage:"balance mode, roundrobin, leastconn or source"`
+	SessionSticky       string     `name:"session_sticky" local:"1" usage:"session sticky, such as insert|3600 or prefix|cookie_name, http only"`
+	Forwardfor          int64      `name:"forwardfor" local:"1" usage:"the bit mask of headers forwarded to backends, 1: X-Forwarded-For, 2: QC-LBID, 4: QC-LBIP, http only"`
+	HealthyCheckMethod  string     `name:"healthy_check_method" local:"1" default:"tcp" usage:"health check method, tcp or http|/path|host"`
+	HealthyCheckOption  string     `name:"healthy_check_option" local:"1" default:"10|5|2|5" usage:"health check option, interval|timeout|unhealthy threshold|healthy threshold"`
+	Timeout             int64      `name:"timeout" local:"1" usage:"the timeout of idle connection, unit second"`
+}
+
+func (alc *addListenerCmd) Send() error {
+	if !validParam(validListenerProtocol, alc.ListenerProtocol) {
+		fmt.Println("listener protocol is invalid, must be one of", validListenerProtocol)
+		os.Exit(0)
+	}
+	if alc.ListenerProtocol == "https" && len(alc.ServerCertificateId) == 0 {
+		fmt.Println("server certificate id is required when protocol is https")
+		os.Exit(0)
+	}
+	if !validParam(validBalanceMode, alc.BalanceMode) {
+		fmt.Println("balance mode is invalid, must be one of", validBalanceMode)
+		os.Exit(0)
+	}
+	alc.Listeners = []listener{alc.listener()}
+	return alc.send(alc)
+}
+
+// listener builds the listener parameter from the flags, the backend protocol is the listener protocol
+// by default, and http for https because tls is terminated by the load balancer.
+func (alc *addListenerCmd) listener() listener {
+	backendProtocol := alc.BackendProtocol
+	if len(backendProtocol) == 0 {
+		backendProtocol = alc.ListenerProtocol
+		if backendProtocol == "https" {
+			backendProtocol = "http"
+		}
+	}
+	return listener{
+		ListenerName:        alc.ListenerName,
+		ListenerPort:        alc.ListenerPort,
+		ListenerProtocol:    alc.ListenerProtocol,
+		BackendProtocol:     backendProtocol,
+		ServerCertificateId: alc.ServerCertificateId,
+		BalanceMode:         alc.BalanceMode,
+		SessionSticky:       alc.SessionSticky,
+		Forwardfor:          alc.Forwardfor,
+		HealthyCheckMethod:  alc.HealthyCheckMethod,
+		HealthyCheckOption:  alc.HealthyCheckOption,
+		Timeout:             alc.Timeout,
+	}
+}
+
+func (alc *addListenerCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*alc), reflect.ValueOf(*alc), reflect.ValueOf(alc), cmd))
+
+	//for completion
+	registerLoadBalancerIdCompletion(cmd, "loadbalancer")
+
+	flagName := "listener_protocol"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validListenerProtocol, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "backend_protocol"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"http", "tcp"}, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "balance_mode"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validBalanceMode, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type deleteListenerCmd struct {
+	instanceCmd
+	LoadBalancerListenerIds []string `name:"loadbalancer_listeners" required:"1" usage:"listener id[s] which want to delete. Multiple listeners, --loadbalancer_listeners lbl1 --loadbalancer_listeners lbl2"`
+}
+
+func (dlc *deleteListenerCmd) Send() error {
+	return dlc.send(dlc)
+}
+
+func (dlc *deleteListenerCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dlc), reflect.ValueOf(*dlc), reflect.ValueOf(dlc), cmd))
+
+	//for completion
+	registerListenerIdCompletion(cmd, "loadbalancer_listeners")
+}
+
+type describeBackendCmd struct {
+	instanceCmd
+	LoadBalancerBackendIds []string `name:"loadbalancer_backends" usage:"backend id[s] which want to fetch. Multiple backends set like --loadbalancer_backends lbb1 --loadbalancer_backends lbb2"`
+	LoadBalancerListenerId string   `name:"loadbalancer_listener" usage:"filter by the listener which backends belong to"`
+	LoadBalancerId         string   `name:"loadbalancer" usage:"filter by the load balancer which backends belong to"`
+	Verbose                bool     `name:"verbose" default:"false" usage:"show detail information or not"`
+	Offset                 int64    `name:"offset" default:"0" usage:"matched backend offset"`
+	Limit                  int64    `name:"limit" default:"20" usage:"matched backend limit, default is 20, max is 100"`
+}
+
+func (dbc *describeBackendCmd) Send() error {
+	if dbc.Limit < 20 || dbc.Limit > 100 {
+		dbc.Limit = 20
+	}
+	return dbc.send(dbc)
+}
+
+func (dbc *describeBackendCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dbc), reflect.ValueOf(*dbc), reflect.ValueOf(dbc), cmd))
+
+	//for completion
+	registerListenerIdCompletion(cmd, "loadbalancer_listener")
+	registerLoadBalancerIdCompletion(cmd, "loadbalancer")
+}
+
+// backend is one element of the backends parameter in AddLoadBalancerBackends.
+type backend struct {
+	BackendName string `name:"loadbalancer_backend_name"`
+	ResourceId  string `name:"resource_id"`
+	Port        int64  `name:"port"`
+	Weight      int64  `name:"weight"`
+}
+
+type addBackendCmd struct {
+	instanceCmd
+	LoadBalancerListenerId string    `name:"loadbalancer_listener" required:"1" usage:"the listener id which backends add to"`
+	Backends               []backend `name:"backends"`
+	InstanceIds            []string  `name:"instances" local:"1" required:"1" usage:"instance id[s] which want to add. Multiple instances, --instances ins1 --instances ins2"`
+	Port                   int64     `name:"port" local:"1" required:"1" usage:"the port of backend instances"`
+	Weight                 int64     `name:"weight" local:"1" default:"5" usage:"the weight of backend instances, between 1 and 100"`
+	BackendName            string    `name:"backend_name" local:"1" usage:"the backend name"`
+}
+
+func (abc *addBackendCmd) Send() error {
+	if abc.Weight < 1 || abc.Weight > 100 {
+		fmt.Println("weight must be between 1 and 100")
+		os.Exit(0)
+	}
+	abc.Backends = abc.backends()
+	return abc.send(abc)
+}
+
+// backends builds one backend of every instance from the flags.
+func (abc *addBackendCmd) backends() []backend {
+	var backends []backend
+	for _, id := range abc.InstanceIds {
+		backends = append(backends, backend{
+			BackendName: abc.BackendName,
+			ResourceId:  id,
+			Port:        abc.Port,
+			Weight:      abc.Weight,
+		})
+	}
+	return backends
+}
+
+func (abc *addBackendCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*abc), reflect.ValueOf(*abc), reflect.ValueOf(abc), cmd))
+
+	//for completion
+	registerListenerIdCompletion(cmd, "loadbalancer_listener")
+	registerInstanceIdCompletion(cmd, "instances")
+}
+
+type deleteBackendCmd struct {
+	instanceCmd
+	LoadBalancerBackendIds []string `name:"loadbalancer_backends" required:"1" usage:"backend id[s] which want to delete. Multiple backends, --loadbalancer_backends lbb1 --loadbalancer_backends lbb2"`
+}
+
+func (dbc *deleteBackendCmd) Send() error {
+	return dbc.send(dbc)
+}
+
+func (dbc *deleteBackendCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dbc), reflect.ValueOf(*dbc), reflect.ValueOf(dbc), cmd))
+}
+
+type modifyBackendCmd struct {
+	instanceCmd
+	LoadBalancerBackendId string `name:"loadbalancer_backend" required:"1" usage:"the backend id which want to modify"`
+	BackendName           string `name:"loadbalancer_backend_name" usage:"the new backend name"`
+	Port                  int64  `name:"port" usage:"the new port of backend"`
+	Weight                string `name:"weight" usage:"the new weight of backend, between 0 and 100, 0 means no traffic"`
+	Disabled              string `name:"disabled" usage:"1: disable the backend, 0: enable the backend"`
+}
+
+func (mbc *modifyBackendCmd) Send() error {
+	if len(mbc.Weight) != 0 {
+		if weight, err := strconv.Atoi(mbc.Weight); err != nil || weight < 0 || weight > 100 {
+			fmt.Println("weight must be between 0 and 100")
+			os.Exit(0)
+		}
+	}
+	return mbc.send(mbc)
+}
+
+func (mbc *modifyBackendCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*mbc), reflect.ValueOf(*mbc), reflect.ValueOf(mbc), cmd))
+}
+
+type drainLoadBalancerBackendCmd struct {
+	instanceCmd
+	InstanceId     string `name:"instance" local:"1" required:"1" usage:"the instance id which want to drain"`
+	LoadBalancerId string `name:"loadbalancer" local:"1" usage:"only drain the backends of this load balancer, default is all load balancers"`
+}
+
+// backendsOfInstance returns the backends of instanceId in items, and the load balancers which they belong to.
+func backendsOfInstance(items []backendItem, instanceId string) ([]backendItem, []string) {
+	var backends []backendItem
+	var loadBalancerIds []string
+	for _, v := range items {
+		if v.ResourceId != instanceId {
+			continue
+		}
+		backends = append(backends, v)
+		if !validParam(loadBalancerIds, v.LoadBalancerId) {
+			loadBalancerIds = append(loadBalancerIds, v.LoadBalancerId)
+		}
+	}
+	return backends, loadBalancerIds
+}
+
+// drainBackendParam sets the weight of backend to 0, weight is a string so that 0 is sent.
+func drainBackendParam(backendId string) *modifyBackendCmd {
+	return &modifyBackendCmd{
+		instanceCmd: instanceCmd{
+			action: "ModifyLoadBalancerBackendAttributes",
+		},
+		LoadBalancerBackendId: backendId,
+		Weight:                "0",
+	}
+}
+
+func (dlc *drainLoadBalancerBackendCmd) Send() error {
+	items, err := describeAllBackends(&describeBackendCmd{
+		instanceCmd: instanceCmd{
+			action: "DescribeLoadBalancerBackends",
+		},
+		LoadBalancerId: dlc.LoadBalancerId,
+	})
+	if err != nil {
+		return err
+	}
+
+	backends, loadBalancerIds := backendsOfInstance(items, dlc.InstanceId)
+	for _, v := range backends {
+		fmt.Printf("drain %s of listener %s, weight %d -> 0\n", v.LoadBalancerBackendId, v.LoadBalancerListenerId, v.Weight)
+		param := drainBackendParam(v.LoadBalancerBackendId)
+		if err := param.request(param, nil); err != nil {
+			return err
+		}
+	}
+	if len(loadBalancerIds) == 0 {
+		fmt.Println("instance", dlc.InstanceId, "is not a backend of any load balancer")
+		return nil
+	}
+
+	param := &updateLoadBalancerCmd{
+		instanceCmd: instanceCmd{
+			action: "UpdateLoadBalancers",
+		},
+		LoadBalancerIds: loadBalancerIds,
+	}
+	resp := apiResponse{}
+	if err := param.request(param, &resp); err != nil {
+		return err
+	}
+	if err := waitJob(resp.JobId); err != nil {
+		return err
+	}
+	fmt.Println("load balancer(s) updated", loadBalancerIds)
+	return nil
+}
+
+func (dlc *drainLoadBalancerBackendCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dlc), reflect.ValueOf(*dlc), reflect.ValueOf(dlc), cmd))
+
+	//for completion
+	registerInstanceIdCompletion(cmd, "instance")
+	registerLoadBalancerIdCompletion(cmd, "loadbalancer")
+}
+
+type describeServerCertificateCmd struct {
+	instanceCmd
+	ServerCertificateIds []string `name:"server_certificates" usage:"certificate id[s] which want to fetch. Multiple certificates set like --server_certificates sc1 --server_certificates sc2"`
+	SearchWord           string   `name:"search_word" usage:"search keyword, certificate id, name are supported"`
+	Verbose              bool     `name:"verbose" default:"false" usage:"show detail information or not"`
+	Offset               int64    `name:"offset" default:"0" usage:"matched certificate offset"`
+	Limit                int64    `name:"limit" default:"20" usage:"matched certificate limit, default is 20, max is 100"`
+}
+
+func (dsc *describeServerCertificateCmd) Send() error {
+	if dsc.Limit < 20 || dsc.Limit > 100 {
+		dsc.Limit = 20
+	}
+	return dsc.send(dsc)
+}
+
+func (dsc *describeServerCertificateCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+}
+
+type createServerCertificateCmd struct {
+	instanceCmd
+	ServerCertificateName string `name:"server_certificate_name" usage:"the certificate name"`
+	CertificateContent    string `name:"certificate_content" usage:"the PEM certificate content, with the intermediate certificates if any"`
+	PrivateKey            string `name:"private_key" usage:"the PEM private key content, --private-key-file is recommended to keep the key out of shell history"`
+	CertificateFile       string `name:"certificate-file" local:"1" usage:"read the certificate content from the local file"`
+	PrivateKeyFile        string `name:"private-key-file" local:"1" usage:"read the private key content from the local file"`
+}
+
+func (csc *createServerCertificateCmd) Send() error {
+	if len(csc.CertificateFile) != 0 {
+		data, err := ioutil.ReadFile(csc.CertificateFile)
+		if err != nil {
+			return err
+		}
+		csc.CertificateContent = string(data)
+	}
+	if len(csc.PrivateKeyFile) != 0 {
+		data, err := ioutil.ReadFile(csc.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		csc.PrivateKey = string(data)
+	}
+	if len(csc.CertificateContent) == 0 || len(csc.PrivateKey) == 0 {
+		fmt.Println("both certificate and private key must be specified, by content or by file")
+		os.Exit(0)
+	}
+	return csc.send(csc)
+}
+
+func (csc *createServerCertificateCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*csc), reflect.ValueOf(*csc), reflect.ValueOf(csc), cmd))
+}
+
+type deleteServerCertificateCmd struct {
+	instanceCmd
+	ServerCertificateIds []string `name:"server_certificates" required:"1" usage:"certificate id[s] which want to delete. Multiple certificates, --server_certificates sc1 --server_certificates sc2"`
+}
+
+func (dsc *deleteServerCertificateCmd) Send() error {
+	return dsc.send(dsc)
+}
+
+func (dsc *deleteServerCertificateCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+}
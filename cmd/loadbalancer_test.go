@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func encodeParam(t *testing.T, param interface{}) url.Values {
+	val := url.Values{}
+	if err := buildUrlValues(reflect.TypeOf(param).Elem(), reflect.ValueOf(param).Elem(), reflect.ValueOf(param), &val); err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func TestDrainBackend(t *testing.T) {
+	items := []backendItem{
+		{LoadBalancerBackendId: "lbb-1", LoadBalancerId: "lb-1", ResourceId: "i-1", Weight: 5},
+		{LoadBalancerBackendId: "lbb-2", LoadBalancerId: "lb-1", ResourceId: "i-1", Weight: 5},
+		{LoadBalancerBackendId: "lbb-3", LoadBalancerId: "lb-2", ResourceId: "i-2", Weight: 5},
+		{LoadBalancerBackendId: "lbb-4", LoadBalancerId: "lb-3", ResourceId: "i-1", Weight: 5},
+	}
+	backends, loadBalancerIds := backendsOfInstance(items, "i-1")
+	if len(backends) != 3 || !reflect.DeepEqual(loadBalancerIds, []string{"lb-1", "lb-3"}) {
+		t.Error("got=", backends, loadBalancerIds)
+	}
+
+	val := encodeParam(t, drainBackendParam("lbb-1"))
+	if val.Get("weight") != "0" || val.Get("loadbalancer_backend") != "lbb-1" {
+		t.Error("weight 0 should be sent, got=", val)
+	}
+}
+
+func TestListenerAndBackendParams(t *testing.T) {
+	alc := &addListenerCmd{
+		LoadBalancerId:      "lb-1",
+		ListenerName:        "web",
+		ListenerPort:        443,
+		ListenerProtocol:    "https",
+		ServerCertificateId: "sc-1",
+		BalanceMode:         "roundrobin",
+		HealthyCheckMethod:  "http|/health|",
+	}
+	alc.Listeners = []listener{alc.listener()}
+	val := encodeParam(t, alc)
+	expected := map[string]string{
+		"loadbalancer":                           "lb-1",
+		"listeners.1.loadbalancer_listener_name": "web",
+		"listeners.1.listener_port":              "443",
+		"listeners.1.listener_protocol":          "https",
+		"listeners.1.backend_protocol":           "http",
+		"listeners.1.server_certificate_id":      "sc-1",
+		"listeners.1.healthy_check_method":       "http|/health|",
+	}
+	for k, v := range expected {
+		if val.Get(k) != v {
+			t.Error(k, "got=", val.Get(k), "expected=", v)
+		}
+	}
+	if _, ok := val["listener_port"]; ok {
+		t.Error("local flags should not be sent, got=", val)
+	}
+
+	//backend protocol is the listener protocol by default, or as given
+	for _, c := range []struct{ listener, backend, expected string }{
+		{"tcp", "", "tcp"},
+		{"http", "", "http"},
+		{"https", "tcp", "tcp"},
+	} {
+		alc := &addListenerCmd{ListenerProtocol: c.listener, BackendProtocol: c.backend}
+		if got := alc.listener().BackendProtocol; got != c.expected {
+			t.Errorf("%s, got=%s, expected=%s", c.listener, got, c.expected)
+		}
+	}
+
+	abc := &addBackendCmd{
+		LoadBalancerListenerId: "lbl-1",
+		InstanceIds:            []string{"i-1", "i-2"},
+		Port:                   80,
+		Weight:                 10,
+		BackendName:            "web",
+	}
+	abc.Backends = abc.backends()
+	val = encodeParam(t, abc)
+	if len(abc.Backends) != 2 || val.Get("backends.2.resource_id") != "i-2" || val.Get("backends.2.weight") != "10" ||
+		val.Get("backends.1.port") != "80" || val.Get("backends.2.loadbalancer_backend_name") != "web" {
+		t.Error("backends, got=", val)
+	}
+	if _, ok := val["instances.1"]; ok {
+		t.Error("local flags should not be sent, got=", val)
+	}
+}
@@ -0,0 +1,811 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+func addManifestCmd(root *cobra.Command) {
+	root.AddCommand(newCommand("plan", "Show the changes to make the resources match the manifest file",
+		&manifestCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}, mode: "plan"}))
+	root.AddCommand(newCommand("apply", "Create, update and delete resources to match the manifest file",
+		&manifestCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}, mode: "apply"}))
+	root.AddCommand(newCommand("destroy", "Delete the resources listed in the manifest file",
+		&manifestCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}, mode: "destroy"}))
+}
+
+var _ QingCloudCmd = (*manifestCmd)(nil)
+
+// manifest is the desired resources, identified by name. If Tag is set, only the resources with the tag
+// are managed: created resources are tagged, and managed resources missing in manifest are deleted.
+type manifest struct {
+	Zone           string                  `yaml:"zone,omitempty"`
+	Tag            string                  `yaml:"tag,omitempty"`
+	Instances      []manifestInstance      `yaml:"instances,omitempty"`
+	Volumes        []manifestVolume        `yaml:"volumes,omitempty"`
+	Eips           []manifestEip           `yaml:"eips,omitempty"`
+	SecurityGroups []manifestSecurityGroup `yaml:"security_groups,omitempty"`
+
+	//prune deletes the unlisted resources even without tag, used by destroy
+	prune bool
+}
+
+// manifestInstance is count instances with the same name and configuration, count is 1 by default.
+type manifestInstance struct {
+	Name          string   `yaml:"name"`
+	Count         int64    `yaml:"count,omitempty"`
+	ImageId       string   `yaml:"image_id"`
+	InstanceType  string   `yaml:"instance_type,omitempty"`
+	CPU           int64    `yaml:"cpu,omitempty"`
+	Memory        int64    `yaml:"memory,omitempty"`
+	Vxnets        []string `yaml:"vxnets,omitempty"`
+	SecurityGroup string   `yaml:"security_group,omitempty"`
+	LoginMode     string   `yaml:"login_mode,omitempty"`
+	LoginKeyPair  string   `yaml:"login_keypair,omitempty"`
+	LoginPasswd   string   `yaml:"login_passwd,omitempty"`
+}
+
+type manifestVolume struct {
+	Name       string `yaml:"name"`
+	Size       int64  `yaml:"size"`
+	VolumeType string `yaml:"volume_type,omitempty"`
+}
+
+type manifestEip struct {
+	Name        string `yaml:"name"`
+	Bandwidth   int64  `yaml:"bandwidth"`
+	BillingMode string `yaml:"billing_mode,omitempty"`
+}
+
+// manifestSecurityGroup is a security group, its rules are synced only if Rules is not nil.
+type manifestSecurityGroup struct {
+	Name  string              `yaml:"name"`
+	Rules []securityGroupRule `yaml:"rules,omitempty"`
+}
+
+// readManifest reads and validates the manifest file.
+func readManifest(file string) (manifest, error) {
+	m := manifest{}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return m, err
+	}
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return m, err
+	}
+	return m, m.validate()
+}
+
+func (m manifest) validate() error {
+	names := make(map[string]bool)
+	unique := func(kind, name string) error {
+		if len(name) == 0 {
+			return fmt.Errorf("%s name is required", kind)
+		}
+		if names[kind+"/"+name] {
+			return fmt.Errorf("duplicate %s name %s", kind, name)
+		}
+		names[kind+"/"+name] = true
+		return nil
+	}
+
+	for _, v := range m.Instances {
+		if err := unique("instance", v.Name); err != nil {
+			return err
+		}
+		if len(v.ImageId) == 0 {
+			return fmt.Errorf("instance %s, image_id is required", v.Name)
+		}
+		if len(v.InstanceType) == 0 && (v.CPU <= 0 || v.Memory <= 0) {
+			return fmt.Errorf("instance %s, instance_type or both cpu and memory are required", v.Name)
+		}
+	}
+	for _, v := range m.Volumes {
+		if err := unique("volume", v.Name); err != nil {
+			return err
+		}
+		if v.Size < 10 || v.Size%10 != 0 {
+			return fmt.Errorf("volume %s, size must be a multiple of 10 and at least 10", v.Name)
+		}
+	}
+	for _, v := range m.Eips {
+		if err := unique("eip", v.Name); err != nil {
+			return err
+		}
+		if v.Bandwidth <= 0 {
+			return fmt.Errorf("eip %s, bandwidth must be greater than 0", v.Name)
+		}
+	}
+	for _, v := range m.SecurityGroups {
+		if err := unique("security_group", v.Name); err != nil {
+			return err
+		}
+		for _, r := range v.Rules {
+			if err := validateSecurityGroupRule(r); err != nil {
+				return fmt.Errorf("security group %s, rule %s, %v", v.Name, r.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pruneUnlisted returns true if the resources missing in manifest should be deleted.
+func (m manifest) pruneUnlisted() bool {
+	return m.prune || len(m.Tag) != 0
+}
+
+// size returns the cpu number and memory size of instance, by cpu and memory or by instance type.
+// The size of instance types such as s1.small.r1 is unknown, which is an error.
+func (mi manifestInstance) size() (int64, int64, error) {
+	if mi.CPU > 0 && mi.Memory > 0 {
+		return mi.CPU, mi.Memory, nil
+	}
+	if len(mi.InstanceType) == 0 {
+		return 0, 0, errors.New("instance_type or both cpu and memory are required")
+	}
+	return instanceTypeSize(mi.InstanceType)
+}
+
+// needResize returns true if the size of item differs from mi. If the size of mi is unknown,
+// the instance types are compared instead.
+func (mi manifestInstance) needResize(item instanceItem) bool {
+	if cpu, memory, err := mi.size(); err == nil {
+		return item.VcpusCurrent != cpu || item.MemoryCurrent != memory
+	}
+	return item.InstanceType != mi.InstanceType
+}
+
+func (mi manifestInstance) count() int64 {
+	if mi.Count < 1 {
+		return 1
+	}
+	return mi.Count
+}
+
+// change is one step to make the resources match the manifest.
+type change struct {
+	Op     string //create, update or delete
+	Kind   string
+	Name   string
+	Ids    []string
+	Detail string
+	apply  func() error
+}
+
+func (c change) String() string {
+	symbol := map[string]string{"create": "+", "update": "~", "delete": "-"}[c.Op]
+	s := fmt.Sprintf("%s %s %s", symbol, c.Kind, c.Name)
+	if len(c.Ids) != 0 {
+		s += " " + strings.Join(c.Ids, ",")
+	}
+	if len(c.Detail) != 0 {
+		s += ": " + c.Detail
+	}
+	return s
+}
+
+// hasTag returns true if tag is empty or tags contains the tag name.
+func hasTag(tags []tagItem, tag string) bool {
+	if len(tag) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		if t.TagName == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// requestJob sends param, decodes the response into out if not nil, and waits for the job if any.
+func requestJob(ic *instanceCmd, param interface{}, out interface{}) error {
+	data, err := ic.requestData(param)
+	if err != nil {
+		return err
+	}
+	resp := apiResponse{}
+	if err := decodeResponse(data, &resp); err != nil {
+		return err
+	}
+	if out != nil {
+		if err := decodeResponse(data, out); err != nil {
+			return err
+		}
+	}
+	if len(resp.JobId) == 0 {
+		return nil
+	}
+	return waitJob(resp.JobId)
+}
+
+// requestCreate sends the create action of param and tags the created ids of the response field idsKey
+// before waiting for the job, so the resources of a failed or timed out job are still found by the tag.
+func requestCreate(ic *instanceCmd, param interface{}, idsKey, tag string) ([]string, error) {
+	data, err := ic.requestData(param)
+	if err != nil {
+		return nil, err
+	}
+	resp := apiResponse{}
+	if err := decodeResponse(data, &resp); err != nil {
+		return nil, err
+	}
+	ids, err := createdIds(data, idsKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := tagCreated(tag, ids); err != nil {
+		return ids, err
+	}
+	if len(resp.JobId) == 0 {
+		return ids, nil
+	}
+	return ids, waitJob(resp.JobId)
+}
+
+// tagCreated attaches the management tag to the created resources.
+func tagCreated(tag string, ids []string) error {
+	if len(tag) == 0 || len(ids) == 0 {
+		return nil
+	}
+	tagId, err := ensureTag("", tag)
+	if err != nil {
+		return err
+	}
+	return attachTags("", []string{tagId}, ids)
+}
+
+// diffInstances returns the changes of instances. Instances are matched by name, the missing ones are created,
+// the extra ones are deleted, and the ones of other size are resized.
+func diffInstances(m manifest, current []instanceItem) []change {
+	byName := make(map[string][]instanceItem)
+	for _, v := range current {
+		if hasTag(v.Tags, m.Tag) {
+			byName[v.InstanceName] = append(byName[v.InstanceName], v)
+		}
+	}
+
+	var changes []change
+	for _, desired := range m.Instances {
+		desired := desired
+		existing := byName[desired.Name]
+		delete(byName, desired.Name)
+		sort.Slice(existing, func(i, j int) bool { return existing[i].InstanceId < existing[j].InstanceId })
+
+		count := desired.count()
+		if missing := count - int64(len(existing)); missing > 0 {
+			changes = append(changes, change{
+				Op:     "create",
+				Kind:   "instance",
+				Name:   desired.Name,
+				Detail: fmt.Sprintf("%d x %s %s", missing, desired.sizeName(), desired.ImageId),
+				apply:  func() error { return createInstances(m.Tag, desired, missing) },
+			})
+		} else if missing < 0 {
+			ids := instanceIds(existing[count:])
+			changes = append(changes, deleteInstancesChange(desired.Name, ids))
+			existing = existing[:count]
+		}
+
+		var resize []string
+		for _, v := range existing {
+			if desired.needResize(v) {
+				resize = append(resize, v.InstanceId)
+			}
+		}
+		if len(resize) != 0 {
+			changes = append(changes, change{
+				Op:     "update",
+				Kind:   "instance",
+				Name:   desired.Name,
+				Ids:    resize,
+				Detail: "resize to " + desired.sizeName(),
+				apply: func() error {
+					param := &resizeInstanceCmd{
+						instanceCmd: instanceCmd{
+							action: "ResizeInstances",
+						},
+						InstanceIds: resize,
+					}
+					if cpu, memory, err := desired.size(); err == nil {
+						param.CPU, param.Memory = cpu, memory
+					} else {
+						param.InstanceType = desired.InstanceType
+					}
+					return param.resizeWithStopStart()
+				},
+			})
+		}
+	}
+
+	//instances missing in manifest are deleted only if they are managed by tag
+	if m.pruneUnlisted() {
+		var names []string
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			changes = append(changes, deleteInstancesChange(name, instanceIds(byName[name])))
+		}
+	}
+	return changes
+}
+
+func (mi manifestInstance) sizeName() string {
+	if mi.CPU > 0 && mi.Memory > 0 {
+		return fmt.Sprintf("%dC%dG", mi.CPU, mi.Memory/1024)
+	}
+	return mi.InstanceType
+}
+
+func instanceIds(items []instanceItem) []string {
+	var ids []string
+	for _, v := range items {
+		ids = append(ids, v.InstanceId)
+	}
+	return ids
+}
+
+func deleteInstancesChange(name string, ids []string) change {
+	return change{
+		Op:   "delete",
+		Kind: "instance",
+		Name: name,
+		Ids:  ids,
+		apply: func() error {
+			param := &terminateInstanceCmd{
+				instanceCmd: instanceCmd{
+					action: "TerminateInstances",
+				},
+				InstanceIds: ids,
+			}
+			return requestJob(&param.instanceCmd, param, nil)
+		},
+	}
+}
+
+// createInstances runs count instances of desired and tags them before the job is done.
+func createInstances(tag string, desired manifestInstance, count int64) error {
+	param := &runInstanceCmd{
+		instanceCmd: instanceCmd{
+			action: "RunInstances",
+		},
+		ImageId:       desired.ImageId,
+		InstanceType:  desired.InstanceType,
+		CPU:           desired.CPU,
+		Memory:        desired.Memory,
+		Count:         count,
+		InstanceName:  desired.Name,
+		LoginMode:     desired.LoginMode,
+		LoginKeyPair:  desired.LoginKeyPair,
+		LoginPasswd:   desired.LoginPasswd,
+		Vxnets:        desired.Vxnets,
+		SecurityGroup: desired.SecurityGroup,
+		NeedNewSid:    true,
+	}
+	instances, jobId, err := param.runInstances()
+	if err != nil {
+		return err
+	}
+	if err := tagCreated(tag, instances); err != nil {
+		return err
+	}
+	return waitJob(jobId)
+}
+
+// diffVolumes returns the changes of volumes, a volume smaller than desired is resized.
+func diffVolumes(m manifest, current []volumeItem) []change {
+	byName := make(map[string][]volumeItem)
+	for _, v := range current {
+		if hasTag(v.Tags, m.Tag) {
+			byName[v.VolumeName] = append(byName[v.VolumeName], v)
+		}
+	}
+	deleteVolumes := func(name string, items []volumeItem) change {
+		var ids []string
+		for _, v := range items {
+			ids = append(ids, v.VolumeId)
+		}
+		return change{Op: "delete", Kind: "volume", Name: name, Ids: ids, apply: func() error {
+			param := &deleteVolumeCmd{instanceCmd: instanceCmd{action: "DeleteVolumes"}, VolumeIds: ids}
+			return requestJob(&param.instanceCmd, param, nil)
+		}}
+	}
+
+	var changes []change
+	for _, desired := range m.Volumes {
+		desired := desired
+		existing := byName[desired.Name]
+		delete(byName, desired.Name)
+		if len(existing) == 0 {
+			changes = append(changes, change{Op: "create", Kind: "volume", Name: desired.Name,
+				Detail: fmt.Sprintf("%dGB", desired.Size), apply: func() error {
+					param := &createVolumeCmd{
+						instanceCmd: instanceCmd{action: "CreateVolumes"},
+						Size:        desired.Size,
+						VolumeName:  desired.Name,
+						VolumeType:  desired.VolumeType,
+						Count:       1,
+					}
+					_, err := requestCreate(&param.instanceCmd, param, "volumes", m.Tag)
+					return err
+				}})
+			continue
+		}
+		if len(existing) > 1 {
+			changes = append(changes, deleteVolumes(desired.Name, existing[1:]))
+		}
+		if v := existing[0]; v.Size < desired.Size {
+			ids := []string{v.VolumeId}
+			changes = append(changes, change{Op: "update", Kind: "volume", Name: desired.Name, Ids: ids,
+				Detail: fmt.Sprintf("resize %dGB -> %dGB", v.Size, desired.Size), apply: func() error {
+					param := &resizeVolumeCmd{instanceCmd: instanceCmd{action: "ResizeVolumes"}, VolumeIds: ids, Size: desired.Size}
+					return requestJob(&param.instanceCmd, param, nil)
+				}})
+		}
+	}
+	if m.pruneUnlisted() {
+		var names []string
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			changes = append(changes, deleteVolumes(name, byName[name]))
+		}
+	}
+	return changes
+}
+
+// diffEips returns the changes of eips, an eip of other bandwidth is changed.
+func diffEips(m manifest, current []eipItem) []change {
+	byName := make(map[string][]eipItem)
+	for _, v := range current {
+		if hasTag(v.Tags, m.Tag) {
+			byName[v.EipName] = append(byName[v.EipName], v)
+		}
+	}
+	releaseEips := func(name string, items []eipItem) change {
+		var ids []string
+		for _, v := range items {
+			ids = append(ids, v.EipId)
+		}
+		return change{Op: "delete", Kind: "eip", Name: name, Ids: ids, apply: func() error {
+			param := &releaseEipCmd{instanceCmd: instanceCmd{action: "ReleaseEips"}, EipIds: ids}
+			return requestJob(&param.instanceCmd, param, nil)
+		}}
+	}
+
+	var changes []change
+	for _, desired := range m.Eips {
+		desired := desired
+		existing := byName[desired.Name]
+		delete(byName, desired.Name)
+		if len(existing) == 0 {
+			changes = append(changes, change{Op: "create", Kind: "eip", Name: desired.Name,
+				Detail: fmt.Sprintf("%dMbps", desired.Bandwidth), apply: func() error {
+					param := &allocateEipCmd{
+						instanceCmd: instanceCmd{action: "AllocateEips"},
+						Bandwidth:   desired.Bandwidth,
+						BillingMode: desired.BillingMode,
+						EipName:     desired.Name,
+						Count:       1,
+					}
+					if len(param.BillingMode) == 0 {
+						param.BillingMode = "bandwidth"
+					}
+					_, err := requestCreate(&param.instanceCmd, param, "eips", m.Tag)
+					return err
+				}})
+			continue
+		}
+		if len(existing) > 1 {
+			changes = append(changes, releaseEips(desired.Name, existing[1:]))
+		}
+		if v := existing[0]; v.Bandwidth != desired.Bandwidth {
+			ids := []string{v.EipId}
+			changes = append(changes, change{Op: "update", Kind: "eip", Name: desired.Name, Ids: ids,
+				Detail: fmt.Sprintf("bandwidth %dMbps -> %dMbps", v.Bandwidth, desired.Bandwidth), apply: func() error {
+					param := &changeEipBandwidthCmd{instanceCmd: instanceCmd{action: "ChangeEipsBandwidth"}, EipIds: ids, Bandwidth: desired.Bandwidth}
+					return requestJob(&param.instanceCmd, param, nil)
+				}})
+		}
+	}
+	if m.pruneUnlisted() {
+		var names []string
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			changes = append(changes, releaseEips(name, byName[name]))
+		}
+	}
+	return changes
+}
+
+// diffSecurityGroups returns the changes of security groups, rules are synced as security-groups sync.
+// rules of the existing security groups are fetched by rulesOf.
+func diffSecurityGroups(m manifest, current []securityGroupItem, rulesOf func(string) ([]securityGroupRuleItem, error)) ([]change, error) {
+	byName := make(map[string][]securityGroupItem)
+	for _, v := range current {
+		if v.IsDefault == 0 && hasTag(v.Tags, m.Tag) {
+			byName[v.SecurityGroupName] = append(byName[v.SecurityGroupName], v)
+		}
+	}
+	deleteSecurityGroups := func(name string, items []securityGroupItem) change {
+		var ids []string
+		for _, v := range items {
+			ids = append(ids, v.SecurityGroupId)
+		}
+		return change{Op: "delete", Kind: "security_group", Name: name, Ids: ids, apply: func() error {
+			param := &deleteSecurityGroupCmd{instanceCmd: instanceCmd{action: "DeleteSecurityGroups"}, SecurityGroupIds: ids}
+			return requestJob(&param.instanceCmd, param, nil)
+		}}
+	}
+
+	var changes []change
+	for _, desired := range m.SecurityGroups {
+		desired := desired
+		existing := byName[desired.Name]
+		delete(byName, desired.Name)
+		if len(existing) == 0 {
+			changes = append(changes, change{Op: "create", Kind: "security_group", Name: desired.Name,
+				Detail: fmt.Sprintf("%d rule(s)", len(desired.Rules)), apply: func() error {
+					param := &createSecurityGroupCmd{instanceCmd: instanceCmd{action: "CreateSecurityGroup"}, SecurityGroupName: desired.Name}
+					ids, err := requestCreate(&param.instanceCmd, param, "security_group_id", m.Tag)
+					if err != nil {
+						return err
+					}
+					//rules are normalized and deduplicated as the existing security groups do
+					toAdd, _ := diffSecurityGroupRules(nil, desired.Rules)
+					return syncSecurityGroupRules(ids[0], toAdd, nil)
+				}})
+			continue
+		}
+		if len(existing) > 1 {
+			changes = append(changes, deleteSecurityGroups(desired.Name, existing[1:]))
+		}
+		if desired.Rules == nil {
+			continue
+		}
+		id := existing[0].SecurityGroupId
+		rules, err := rulesOf(id)
+		if err != nil {
+			return nil, err
+		}
+		toAdd, toDelete := diffSecurityGroupRules(rules, desired.Rules)
+		if len(toAdd) != 0 || len(toDelete) != 0 {
+			changes = append(changes, change{Op: "update", Kind: "security_group", Name: desired.Name, Ids: []string{id},
+				Detail: fmt.Sprintf("add %d rule(s), delete %d rule(s)", len(toAdd), len(toDelete)), apply: func() error {
+					return syncSecurityGroupRules(id, toAdd, toDelete)
+				}})
+		}
+	}
+	if m.pruneUnlisted() {
+		var names []string
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			changes = append(changes, deleteSecurityGroups(name, byName[name]))
+		}
+	}
+	return changes, nil
+}
+
+// syncSecurityGroupRules adds and deletes the rules, then applies the security group.
+func syncSecurityGroupRules(securityGroupId string, toAdd []securityGroupRule, toDelete []securityGroupRuleItem) error {
+	//add the new rules before deleting the old ones, so the group never loses the rules which allow access
+	if len(toAdd) != 0 {
+		param := &addSecurityGroupRuleCmd{
+			instanceCmd:     instanceCmd{action: "AddSecurityGroupRules"},
+			SecurityGroupId: securityGroupId,
+			Rules:           toAdd,
+		}
+		if err := param.request(param, nil); err != nil {
+			return err
+		}
+	}
+	if len(toDelete) != 0 {
+		param := &deleteSecurityGroupRuleCmd{instanceCmd: instanceCmd{action: "DeleteSecurityGroupRules"}}
+		for _, item := range toDelete {
+			param.SecurityGroupRuleIds = append(param.SecurityGroupRuleIds, item.SecurityGroupRuleId)
+		}
+		if err := param.request(param, nil); err != nil {
+			return err
+		}
+	}
+	param := &applySecurityGroupCmd{instanceCmd: instanceCmd{action: "ApplySecurityGroup"}, SecurityGroupId: securityGroupId}
+	return requestJob(&param.instanceCmd, param, nil)
+}
+
+// currentResources fetches the instances, volumes, eips and security groups which may be managed by m.
+func currentResources(m manifest) ([]instanceItem, []volumeItem, []eipItem, []securityGroupItem, error) {
+	instances, err := inventoryInstances("", "")
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var volumes []volumeItem
+	if len(m.Volumes) != 0 || len(m.Tag) != 0 {
+		items, err := describeAllVolumes(&describeVolumeCmd{instanceCmd: instanceCmd{action: "DescribeVolumes"}})
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		for _, v := range items {
+			if v.Status != "deleted" && v.Status != "ceased" {
+				volumes = append(volumes, v)
+			}
+		}
+	}
+
+	var eips []eipItem
+	if len(m.Eips) != 0 || len(m.Tag) != 0 {
+		items, err := describeAllEips(&describeEipCmd{instanceCmd: instanceCmd{action: "DescribeEips"}})
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		for _, v := range items {
+			if v.Status != "released" && v.Status != "ceased" {
+				eips = append(eips, v)
+			}
+		}
+	}
+
+	var securityGroups []securityGroupItem
+	if len(m.SecurityGroups) != 0 || len(m.Tag) != 0 {
+		if securityGroups, err = describeAllSecurityGroups(&describeSecurityGroupCmd{instanceCmd: instanceCmd{action: "DescribeSecurityGroups"}}); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+	return instances, volumes, eips, securityGroups, nil
+}
+
+// planManifest returns the changes to make the resources match m, in the order to apply:
+// security groups, eips and volumes are created before instances, and deleted after instances.
+func planManifest(m manifest) ([]change, error) {
+	instances, volumes, eips, securityGroups, err := currentResources(m)
+	if err != nil {
+		return nil, err
+	}
+	sgChanges, err := diffSecurityGroups(m, securityGroups, describeAllSecurityGroupRules)
+	if err != nil {
+		return nil, err
+	}
+	return orderChanges(diffInstances(m, instances), diffVolumes(m, volumes), diffEips(m, eips), sgChanges), nil
+}
+
+// orderChanges orders the changes of instances and the other resources which instances may depend on.
+func orderChanges(instanceChanges []change, others ...[]change) []change {
+	var ordered []change
+	for _, op := range []string{"create", "update"} {
+		for i := len(others) - 1; i >= 0; i-- {
+			for _, c := range others[i] {
+				if c.Op == op {
+					ordered = append(ordered, c)
+				}
+			}
+		}
+		for _, c := range instanceChanges {
+			if c.Op == op {
+				ordered = append(ordered, c)
+			}
+		}
+	}
+	for _, c := range instanceChanges {
+		if c.Op == "delete" {
+			ordered = append(ordered, c)
+		}
+	}
+	for _, list := range others {
+		for _, c := range list {
+			if c.Op == "delete" {
+				ordered = append(ordered, c)
+			}
+		}
+	}
+	return ordered
+}
+
+// destroyManifest returns the changes to delete the resources listed in m.
+func destroyManifest(m manifest) ([]change, error) {
+	instances, volumes, eips, securityGroups, err := currentResources(m)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool)
+	for _, v := range m.Instances {
+		listed["instance/"+v.Name] = true
+	}
+	for _, v := range m.Volumes {
+		listed["volume/"+v.Name] = true
+	}
+	for _, v := range m.Eips {
+		listed["eip/"+v.Name] = true
+	}
+	for _, v := range m.SecurityGroups {
+		listed["security_group/"+v.Name] = true
+	}
+	//every managed resource is unlisted in an empty manifest, so it's deleted
+	empty := manifest{Tag: m.Tag, prune: true}
+	sgChanges, err := diffSecurityGroups(empty, securityGroups, describeAllSecurityGroupRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []change
+	for _, c := range orderChanges(diffInstances(empty, instances), diffVolumes(empty, volumes), diffEips(empty, eips), sgChanges) {
+		if listed[c.Kind+"/"+c.Name] {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+type manifestCmd struct {
+	instanceCmd
+	mode string //plan, apply or destroy
+	File string `name:"file" shorthand:"f" local:"1" required:"1" usage:"the manifest yaml file, such as infra.yaml"`
+	Yes  bool   `name:"yes" local:"1" default:"false" usage:"apply or destroy without confirmation"`
+}
+
+func (mc *manifestCmd) Send() error {
+	m, err := readManifest(mc.File)
+	if err != nil {
+		return err
+	}
+	//zone of manifest is used only if --zone is not specified
+	if len(zone) == 0 && len(m.Zone) != 0 {
+		zone = m.Zone
+	}
+	mc.commonParam()
+
+	var changes []change
+	if mc.mode == "destroy" {
+		changes, err = destroyManifest(m)
+	} else {
+		changes, err = planManifest(m)
+	}
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes, the resources match the manifest.")
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, c := range changes {
+		fmt.Println(c)
+		counts[c.Op]++
+	}
+	fmt.Printf("Plan: %d to create, %d to update, %d to delete.\n", counts["create"], counts["update"], counts["delete"])
+	if mc.mode == "plan" {
+		return nil
+	}
+	if !mc.Yes && !confirm(fmt.Sprintf("Apply the %d change(s) above?", len(changes))) {
+		fmt.Println("canceled")
+		return nil
+	}
+
+	for _, c := range changes {
+		fmt.Println("applying", c)
+		if err := c.apply(); err != nil {
+			return fmt.Errorf("%s %s %s, %v", c.Op, c.Kind, c.Name, err)
+		}
+	}
+	fmt.Println("Applied", len(changes), "change(s).")
+	return nil
+}
+
+func (mc *manifestCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*mc), reflect.ValueOf(*mc), reflect.ValueOf(mc), cmd))
+}
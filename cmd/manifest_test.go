@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func changeStrings(changes []change) []string {
+	var lines []string
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+	return lines
+}
+
+func expectChanges(t *testing.T, changes []change, expected []string) {
+	t.Helper()
+	lines := changeStrings(changes)
+	if len(lines) != len(expected) {
+		t.Fatalf("got=%q, expected=%q", lines, expected)
+	}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Errorf("%d, got=%s, expected=%s", i, lines[i], expected[i])
+		}
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	m := manifest{Instances: []manifestInstance{{Name: "web", ImageId: "img-1", InstanceType: "c2m4"}, {Name: "db", ImageId: "img-1", InstanceType: "s1.small.r1"}}}
+	if err := m.validate(); err != nil {
+		t.Fatal("valid manifest, got=", err)
+	}
+	m.SecurityGroups = []manifestSecurityGroup{{Name: "web", Rules: []securityGroupRule{{Protocol: "TCP", StartPort: "80"}}}}
+	if err := m.validate(); err != nil {
+		t.Fatal("rules are validated after normalized, got=", err)
+	}
+
+	invalid := []manifest{
+		{Instances: []manifestInstance{{Name: "web", InstanceType: "c2m4"}}},
+		{Instances: []manifestInstance{{Name: "web", ImageId: "img-1"}}},
+		{Instances: []manifestInstance{{Name: "web", ImageId: "img-1", CPU: 1, Memory: 1024}, {Name: "web", ImageId: "img-1", CPU: 1, Memory: 1024}}},
+		{Volumes: []manifestVolume{{Name: "data", Size: 15}}},
+		{Eips: []manifestEip{{Name: "web"}}},
+		{SecurityGroups: []manifestSecurityGroup{{}}},
+		{SecurityGroups: []manifestSecurityGroup{{Name: "web", Rules: []securityGroupRule{{Protocol: "tcpx"}}}}},
+		{SecurityGroups: []manifestSecurityGroup{{Name: "web", Rules: []securityGroupRule{{Protocol: "tcp", Priority: "101"}}}}},
+	}
+	for i, m := range invalid {
+		if err := m.validate(); err == nil {
+			t.Errorf("%d, expected an error", i)
+		}
+	}
+}
+
+func TestDiffInstances(t *testing.T) {
+	managed := []tagItem{{TagName: "infra"}}
+	current := []instanceItem{
+		{InstanceId: "i-2", InstanceName: "web", VcpusCurrent: 2, MemoryCurrent: 4096, Tags: managed},
+		{InstanceId: "i-1", InstanceName: "web", VcpusCurrent: 1, MemoryCurrent: 2048, Tags: managed},
+		{InstanceId: "i-3", InstanceName: "db", VcpusCurrent: 2, MemoryCurrent: 4096, Tags: managed},
+		{InstanceId: "i-4", InstanceName: "old", Tags: managed},
+		{InstanceId: "i-5", InstanceName: "other"},
+	}
+	m := manifest{
+		Tag: "infra",
+		Instances: []manifestInstance{
+			{Name: "web", Count: 1, ImageId: "img-1", InstanceType: "c2m4"},
+			{Name: "db", Count: 3, ImageId: "img-1", CPU: 2, Memory: 4096},
+		},
+	}
+	expectChanges(t, diffInstances(m, current), []string{
+		"- instance web i-2",
+		"~ instance web i-1: resize to c2m4",
+		"+ instance db: 2 x 2C4G img-1",
+		"- instance old i-4",
+	})
+
+	//without tag, unlisted instances are kept
+	m.Tag = ""
+	expectChanges(t, diffInstances(m, current), []string{
+		"- instance web i-2",
+		"~ instance web i-1: resize to c2m4",
+		"+ instance db: 2 x 2C4G img-1",
+	})
+
+	//the size of s1.small.r1 is unknown, instance types are compared
+	m = manifest{Instances: []manifestInstance{{Name: "web", Count: 2, ImageId: "img-1", InstanceType: "s1.small.r1"}}}
+	current = []instanceItem{
+		{InstanceId: "i-1", InstanceName: "web", InstanceType: "s1.small.r1", VcpusCurrent: 1, MemoryCurrent: 1024},
+		{InstanceId: "i-2", InstanceName: "web", InstanceType: "c1m1", VcpusCurrent: 1, MemoryCurrent: 1024},
+	}
+	expectChanges(t, diffInstances(m, current), []string{
+		"~ instance web i-2: resize to s1.small.r1",
+	})
+}
+
+func TestDiffVolumesAndEips(t *testing.T) {
+	m := manifest{
+		Volumes: []manifestVolume{{Name: "data", Size: 100}, {Name: "logs", Size: 10}},
+		Eips:    []manifestEip{{Name: "web", Bandwidth: 5}},
+	}
+	expectChanges(t, diffVolumes(m, []volumeItem{{VolumeId: "vol-1", VolumeName: "data", Size: 50}}), []string{
+		"~ volume data vol-1: resize 50GB -> 100GB",
+		"+ volume logs: 10GB",
+	})
+	expectChanges(t, diffEips(m, []eipItem{{EipId: "eip-1", EipName: "web", Bandwidth: 5}, {EipId: "eip-2", EipName: "spare"}}), nil)
+}
+
+func TestOrderChanges(t *testing.T) {
+	instances := []change{{Op: "delete", Kind: "instance", Name: "a"}, {Op: "create", Kind: "instance", Name: "b"}}
+	volumes := []change{{Op: "create", Kind: "volume", Name: "c"}, {Op: "delete", Kind: "volume", Name: "d"}}
+	securityGroups := []change{{Op: "delete", Kind: "security_group", Name: "e"}, {Op: "create", Kind: "security_group", Name: "f"}}
+	expectChanges(t, orderChanges(instances, volumes, securityGroups), []string{
+		"+ security_group f",
+		"+ volume c",
+		"+ instance b",
+		"- instance a",
+		"- volume d",
+		"- security_group e",
+	})
+}
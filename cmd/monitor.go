@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var validMonitorStep = []string{"5m", "15m", "2h", "1d"}
+var validMonitorMeter = []string{"cpu", "memory", "disk-os", "disk-iops", "if-traffic"}
+var validMonitorOutput = []string{"chart", "json", "csv"}
+
+// sparkLevels are the ascii characters of sparkline, from low to high.
+const sparkLevels = "_.-:=+*#%@"
+const sparkWidth = 60
+
+func addMonitorCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "monitor",
+		Short: "Fetch the monitoring data of resources, print as chart, json or csv",
+	}
+
+	cmd.AddCommand(newCommand("instance", "Fetch the monitoring data of an instance, such as cpu, memory, disk iops and traffic",
+		&monitorInstanceCmd{instanceCmd: instanceCmd{action: "GetMonitor"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*monitorInstanceCmd)(nil)
+
+// monitorPoint is a decoded point of monitoring data, Values is nil if the point is missing.
+// Most meters have one value, disk and traffic meters have two, such as read and write.
+type monitorPoint struct {
+	Time   time.Time `json:"time"`
+	Values []float64 `json:"values"`
+}
+
+// monitorSeries is the decoded monitoring data of one meter.
+type monitorSeries struct {
+	Meter  string         `json:"meter"`
+	Points []monitorPoint `json:"points"`
+}
+
+// decodeMonitorValue decodes a value of monitoring data, which is a number, a list of numbers or "NA".
+func decodeMonitorValue(data json.RawMessage) ([]float64, error) {
+	var number float64
+	if err := json.Unmarshal(data, &number); err == nil {
+		return []float64{number}, nil
+	}
+	var list []float64
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil || string(data) == "null" {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid monitoring value %s", data)
+}
+
+// decodeMonitorData decodes the compressed monitoring data of GetMonitor. The first element is
+// [timestamp, value], the following elements are values one step after the previous one,
+// or [seconds after the previous one, value] if there is a gap.
+func decodeMonitorData(data []json.RawMessage, step time.Duration) ([]monitorPoint, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var first []json.RawMessage
+	if err := json.Unmarshal(data[0], &first); err != nil || len(first) != 2 {
+		return nil, errors.New("invalid monitoring data, the first element must be [timestamp, value]")
+	}
+	var timestamp int64
+	if err := json.Unmarshal(first[0], &timestamp); err != nil {
+		return nil, fmt.Errorf("invalid monitoring timestamp %s", first[0])
+	}
+	values, err := decodeMonitorValue(first[1])
+	if err != nil {
+		return nil, err
+	}
+	//a multiple values meter has list values, so a list is a gap only if its second element is a list too
+	multiple := len(first[1]) != 0 && first[1][0] == '['
+
+	t := time.Unix(timestamp, 0).UTC()
+	points := []monitorPoint{{Time: t, Values: values}}
+	for _, elem := range data[1:] {
+		next, raw := t.Add(step), elem
+		var gap []json.RawMessage
+		if err := json.Unmarshal(elem, &gap); err == nil && len(gap) == 2 && (!multiple || gap[1][0] == '[') {
+			var seconds int64
+			if err := json.Unmarshal(gap[0], &seconds); err != nil {
+				return nil, fmt.Errorf("invalid monitoring gap %s", elem)
+			}
+			next, raw = t.Add(time.Duration(seconds)*time.Second), gap[1]
+		}
+		values, err := decodeMonitorValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		t = next
+		points = append(points, monitorPoint{Time: t, Values: values})
+	}
+	return points, nil
+}
+
+// sparkline renders the index-th value of points as ascii characters, at most width characters.
+// Points are averaged into buckets if there are more than width, missing points are blank.
+func sparkline(points []monitorPoint, index, width int) (string, float64, float64) {
+	var buckets []float64
+	var valid []bool
+	size := int(math.Ceil(float64(len(points)) / float64(width)))
+	if size < 1 {
+		size = 1
+	}
+	for i := 0; i < len(points); i += size {
+		var sum float64
+		var n int
+		for _, p := range points[i:minInt(i+size, len(points))] {
+			if index < len(p.Values) {
+				sum += p.Values[index]
+				n++
+			}
+		}
+		if n == 0 {
+			buckets, valid = append(buckets, 0), append(valid, false)
+		} else {
+			buckets, valid = append(buckets, sum/float64(n)), append(valid, true)
+		}
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, v := range buckets {
+		if valid[i] {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	var b strings.Builder
+	for i, v := range buckets {
+		switch {
+		case !valid[i]:
+			b.WriteByte(' ')
+		case hi == lo:
+			b.WriteByte(sparkLevels[0])
+		default:
+			level := int((v - lo) / (hi - lo) * float64(len(sparkLevels)-1))
+			b.WriteByte(sparkLevels[level])
+		}
+	}
+	if math.IsInf(lo, 0) {
+		lo, hi = 0, 0
+	}
+	return b.String(), lo, hi
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func printMonitorChart(series []monitorSeries) {
+	for _, s := range series {
+		n := 0
+		for _, p := range s.Points {
+			if len(p.Values) > n {
+				n = len(p.Values)
+			}
+		}
+		if n == 0 {
+			fmt.Printf("%-24s no data\n", s.Meter)
+			continue
+		}
+		for i := 0; i < n; i++ {
+			name := s.Meter
+			if n > 1 {
+				name = fmt.Sprintf("%s[%d]", s.Meter, i)
+			}
+			line, lo, hi := sparkline(s.Points, i, sparkWidth)
+			fmt.Printf("%-24s |%s| min=%g max=%g\n", name, line, lo, hi)
+		}
+	}
+}
+
+func printMonitorCsv(series []monitorSeries) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"meter", "time", "values"})
+	for _, s := range series {
+		for _, p := range s.Points {
+			record := []string{s.Meter, p.Time.Format(time.RFC3339)}
+			for _, v := range p.Values {
+				record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+			w.Write(record)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// getMonitorCmd is used internally to send GetMonitor.
+type getMonitorCmd struct {
+	instanceCmd
+	Resource  string   `name:"resource"`
+	Meters    []string `name:"meters"`
+	Step      string   `name:"step"`
+	StartTime string   `name:"start_time"`
+	EndTime   string   `name:"end_time"`
+}
+
+// getMonitor fetches and decodes the monitoring data of param.
+func getMonitor(param *getMonitorCmd, step time.Duration) ([]monitorSeries, error) {
+	type response struct {
+		MeterSet []struct {
+			MeterId string            `json:"meter_id"`
+			Data    []json.RawMessage `json:"data"`
+		} `json:"meter_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	var series []monitorSeries
+	for _, v := range resp.MeterSet {
+		points, err := decodeMonitorData(v.Data, step)
+		if err != nil {
+			return nil, fmt.Errorf("meter %s, %v", v.MeterId, err)
+		}
+		series = append(series, monitorSeries{Meter: v.MeterId, Points: points})
+	}
+	return series, nil
+}
+
+type monitorInstanceCmd struct {
+	instanceCmd
+	InstanceId string   `name:"instance" local:"1" required:"1" usage:"the instance id"`
+	Meters     []string `name:"meters" local:"1" usage:"meter[s] which want to fetch, cpu, memory, disk-os, disk-iops or if-traffic, default is cpu and memory. Multiple meters, --meters cpu,memory"`
+	Step       string   `name:"step" local:"1" default:"5m" usage:"the interval of points, 5m, 15m, 2h or 1d"`
+	Since      string   `name:"since" local:"1" default:"6h" usage:"fetch the data since the duration ago, such as 6h, 7d"`
+	Output     string   `name:"output" local:"1" default:"chart" usage:"output format, chart, json or csv"`
+}
+
+// meterIds returns the meter ids of GetMonitor for meters, if-traffic is expanded to every nic of the instance.
+func (mic *monitorInstanceCmd) meterIds(meters []string) ([]string, error) {
+	var ids []string
+	for _, m := range meters {
+		switch m {
+		case "disk-iops":
+			ids = append(ids, "disk-iops-os")
+		case "if-traffic":
+			items, err := describeInstances(&describeInstanceCmd{
+				instanceCmd: instanceCmd{
+					action: "DescribeInstances",
+				},
+				InstanceIds: []string{mic.InstanceId},
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				for _, v := range item.Vxnets {
+					ids = append(ids, "if-"+v.NicId)
+				}
+			}
+		default:
+			ids = append(ids, m)
+		}
+	}
+	return ids, nil
+}
+
+func (mic *monitorInstanceCmd) Send() error {
+	var meters []string
+	for _, v := range mic.Meters {
+		meters = append(meters, strings.Split(v, ",")...)
+	}
+	if len(meters) == 0 {
+		meters = []string{"cpu", "memory"}
+	}
+	for _, m := range meters {
+		if !validParam(validMonitorMeter, m) {
+			fmt.Println("meter is invalid, must be one of", validMonitorMeter)
+			os.Exit(0)
+		}
+	}
+	if !validParam(validMonitorStep, mic.Step) {
+		fmt.Println("step is invalid, must be one of", validMonitorStep)
+		os.Exit(0)
+	}
+	if !validParam(validMonitorOutput, mic.Output) {
+		fmt.Println("output format is invalid, must be one of", validMonitorOutput)
+		os.Exit(0)
+	}
+	step, err := parseDuration(mic.Step)
+	if err != nil {
+		return err
+	}
+	since, err := parseDuration(mic.Since)
+	if err != nil {
+		return err
+	}
+
+	ids, err := mic.meterIds(meters)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	series, err := getMonitor(&getMonitorCmd{
+		instanceCmd: instanceCmd{
+			action: "GetMonitor",
+		},
+		Resource:  mic.InstanceId,
+		Meters:    ids,
+		Step:      mic.Step,
+		StartTime: now.Add(-since).Format("2006-01-02T15:04:05Z"),
+		EndTime:   now.Format("2006-01-02T15:04:05Z"),
+	}, step)
+	if err != nil {
+		return err
+	}
+
+	switch mic.Output {
+	case "json":
+		data, err := json.MarshalIndent(series, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "csv":
+		return printMonitorCsv(series)
+	default:
+		printMonitorChart(series)
+	}
+	return nil
+}
+
+func (mic *monitorInstanceCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*mic), reflect.ValueOf(*mic), reflect.ValueOf(mic), cmd))
+
+	//for completion
+	registerInstanceIdCompletion(cmd, "instance")
+
+	flagName := "meters"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validMonitorMeter, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "step"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validMonitorStep, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "output"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validMonitorOutput, cobra.ShellCompDirectiveDefault
+	})
+}
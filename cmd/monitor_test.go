@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDecodeMonitorData(t *testing.T) {
+	var single, multiple []json.RawMessage
+	json.Unmarshal([]byte(`[[1600000000, 10], 20, "NA", [900, 40]]`), &single)
+	json.Unmarshal([]byte(`[[1600000000, [1, 2]], [3, 4], [600, [5, 6]]]`), &multiple)
+
+	points, err := decodeMonitorData(single, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1600000000, 0).UTC()
+	expected := []monitorPoint{
+		{Time: start, Values: []float64{10}},
+		{Time: start.Add(5 * time.Minute), Values: []float64{20}},
+		{Time: start.Add(10 * time.Minute)},
+		{Time: start.Add(25 * time.Minute), Values: []float64{40}},
+	}
+	checkMonitorPoints(t, points, expected)
+
+	points, err = decodeMonitorData(multiple, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []monitorPoint{
+		{Time: start, Values: []float64{1, 2}},
+		{Time: start.Add(5 * time.Minute), Values: []float64{3, 4}},
+		{Time: start.Add(15 * time.Minute), Values: []float64{5, 6}},
+	}
+	checkMonitorPoints(t, points, expected)
+}
+
+func checkMonitorPoints(t *testing.T, points, expected []monitorPoint) {
+	if len(points) != len(expected) {
+		t.Fatal("points, got=", points, "expected=", expected)
+	}
+	for i, p := range points {
+		if !p.Time.Equal(expected[i].Time) || len(p.Values) != len(expected[i].Values) {
+			t.Errorf("points[%d], got=%v, expected=%v", i, p, expected[i])
+			continue
+		}
+		for j, v := range p.Values {
+			if v != expected[i].Values[j] {
+				t.Errorf("points[%d], got=%v, expected=%v", i, p, expected[i])
+			}
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	var points []monitorPoint
+	for _, v := range []float64{0, 9, 0, 9} {
+		points = append(points, monitorPoint{Values: []float64{v}})
+	}
+	points = append(points, monitorPoint{})
+
+	line, lo, hi := sparkline(points, 0, 10)
+	if line != "_@_@ " || lo != 0 || hi != 9 {
+		t.Errorf("got=%q min=%g max=%g", line, lo, hi)
+	}
+
+	//averaged into buckets of two points
+	line, _, _ = sparkline(points[:4], 0, 2)
+	if line != "__" {
+		t.Errorf("bucket, got=%q", line)
+	}
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+	"strconv"
+	"text/tabwriter"
+)
+
+// hoursPerMonth is used to convert between the hourly and the monthly cost.
+const hoursPerMonth = 720
+
+func addPriceCmd(root *cobra.Command) {
+	root.AddCommand(newCommand("price", "Estimate the hourly and monthly cost of instances by GetPrice, nothing is created",
+		&priceCmd{instanceCmd: instanceCmd{action: "GetPrice"}}))
+}
+
+var _ QingCloudCmd = (*priceCmd)(nil)
+
+// priceResource is one element of the resources parameter in GetPrice.
+type priceResource struct {
+	Sequence      int64  `name:"sequence"`
+	Type          string `name:"type"`
+	InstanceType  string `name:"instance_type"`
+	CPU           int64  `name:"cpu"`
+	Memory        int64  `name:"memory"`
+	OsDiskSize    int64  `name:"os_disk_size"`
+	InstanceClass string `name:"instance_class"`
+	ChargeMode    string `name:"charge_mode"`
+	Duration      int64  `name:"duration"`
+}
+
+// priceValue is a price in response, which may be encoded as number or string.
+type priceValue float64
+
+func (p *priceValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		f, err := strconv.ParseFloat(s, 64)
+		*p = priceValue(f)
+		return err
+	}
+	var f float64
+	err := json.Unmarshal(data, &f)
+	*p = priceValue(f)
+	return err
+}
+
+// instanceSize is the billing size of instances, count is how many instances have the size.
+type instanceSize struct {
+	InstanceType  string
+	CPU           int64
+	Memory        int64
+	OsDiskSize    int64
+	InstanceClass string
+	Count         int64
+}
+
+func (s instanceSize) String() string {
+	name := s.InstanceType
+	if s.CPU > 0 && s.Memory > 0 {
+		name = fmt.Sprintf("%dC%dG", s.CPU, s.Memory/1024)
+	}
+	if s.OsDiskSize > 0 {
+		name += fmt.Sprintf(" os_disk:%dGB", s.OsDiskSize)
+	}
+	if len(s.InstanceClass) != 0 {
+		name += " class:" + s.InstanceClass
+	}
+	return name
+}
+
+// groupInstanceSizes counts items by their billing size, in the order of first appearance.
+func groupInstanceSizes(items []instanceItem) []instanceSize {
+	var sizes []instanceSize
+	index := make(map[instanceSize]int)
+	for _, v := range items {
+		key := instanceSize{
+			CPU:           v.VcpusCurrent,
+			Memory:        v.MemoryCurrent,
+			InstanceClass: strconv.FormatInt(v.InstanceClass, 10),
+		}
+		if key.CPU == 0 || key.Memory == 0 {
+			key = instanceSize{InstanceType: v.InstanceType, InstanceClass: key.InstanceClass}
+		}
+		if i, ok := index[key]; ok {
+			sizes[i].Count++
+			continue
+		}
+		index[key] = len(sizes)
+		key.Count = 1
+		sizes = append(sizes, key)
+	}
+	return sizes
+}
+
+// hourlyCost converts price returned by GetPrice to the hourly cost. If months is positive,
+// price is charged monthly for the whole months, otherwise price is charged hourly.
+func hourlyCost(price float64, months int64) float64 {
+	if months > 0 {
+		return price / float64(months) / hoursPerMonth
+	}
+	return price
+}
+
+// priceSequence is one element of price_set in GetPrice response.
+type priceSequence struct {
+	Sequence int64      `json:"sequence"`
+	Price    priceValue `json:"price"`
+}
+
+// newPriceCmd builds GetPrice of one instance of every size. Sequence starts at 1,
+// because a non-positive int64 parameter is not sent.
+func newPriceCmd(sizes []instanceSize, months int64) *priceCmd {
+	param := &priceCmd{
+		instanceCmd: instanceCmd{
+			action: "GetPrice",
+		},
+	}
+	for i, s := range sizes {
+		resource := priceResource{
+			Sequence:      int64(i + 1),
+			Type:          "instance",
+			InstanceType:  s.InstanceType,
+			CPU:           s.CPU,
+			Memory:        s.Memory,
+			OsDiskSize:    s.OsDiskSize,
+			InstanceClass: s.InstanceClass,
+			ChargeMode:    "elastic",
+		}
+		if months > 0 {
+			resource.ChargeMode = "monthly"
+			resource.Duration = months
+		}
+		param.Resources = append(param.Resources, resource)
+	}
+	return param
+}
+
+// sequencePrices maps price_set back to the order of n sizes by sequence.
+func sequencePrices(priceSet []priceSequence, n int) ([]float64, error) {
+	prices := make([]float64, n)
+	for _, v := range priceSet {
+		if v.Sequence < 1 || v.Sequence > int64(n) {
+			return nil, fmt.Errorf("unexpected sequence %d in price_set", v.Sequence)
+		}
+		prices[v.Sequence-1] = float64(v.Price)
+	}
+	return prices, nil
+}
+
+// getPrices returns the price of one instance of every size, in the order of sizes.
+func getPrices(sizes []instanceSize, months int64) ([]float64, error) {
+	type response struct {
+		PriceSet []priceSequence `json:"price_set"`
+	}
+
+	param := newPriceCmd(sizes, months)
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return sequencePrices(resp.PriceSet, len(sizes))
+}
+
+// estimateCost prints the hourly and monthly cost of sizes, and the total.
+func estimateCost(sizes []instanceSize, months int64) error {
+	if len(sizes) == 0 {
+		fmt.Println("no instance to estimate")
+		return nil
+	}
+	prices, err := getPrices(sizes, months)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tCOUNT\tHOURLY\tMONTHLY")
+	var total float64
+	for i, s := range sizes {
+		hourly := hourlyCost(prices[i], months) * float64(s.Count)
+		total += hourly
+		fmt.Fprintf(w, "%s\t%d\t%.4f\t%.2f\n", s, s.Count, hourly, hourly*hoursPerMonth)
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%.4f\t%.2f\n", total, total*hoursPerMonth)
+	return w.Flush()
+}
+
+type priceCmd struct {
+	instanceCmd
+	Resources     []priceResource `name:"resources"`
+	InstanceType  string          `name:"instance_type" local:"1" usage:"the instance type. If instance_type was specified, cpu and memory were not required, otherwise both cpu and memory were required."`
+	CPU           int64           `name:"cpu" local:"1" usage:"cpu number"`
+	Memory        int64           `name:"memory" local:"1" usage:"memory size, unit MB"`
+	OsDiskSize    int64           `name:"os_disk_size" local:"1" usage:"the size of OS disk, unit GB"`
+	InstanceClass string          `name:"instance_class" local:"1" usage:"instance performance category, 0: high performance, 1: super high performance,101: basic, 201: enterprise"`
+	Count         int64           `name:"count" local:"1" default:"1" usage:"the count of instances"`
+	Months        int64           `name:"months" local:"1" usage:"charge monthly for the months, default is charged hourly"`
+}
+
+func (pc *priceCmd) Send() error {
+	if !(pc.CPU > 0 && pc.Memory > 0) && len(pc.InstanceType) == 0 {
+		fmt.Println("instance_type or both cpu and memory are required")
+		os.Exit(0)
+	}
+	checkInstanceSize(pc.InstanceType, pc.CPU, pc.Memory)
+	if pc.Count < 1 {
+		pc.Count = 1
+	}
+	return estimateCost([]instanceSize{{
+		InstanceType:  pc.InstanceType,
+		CPU:           pc.CPU,
+		Memory:        pc.Memory,
+		OsDiskSize:    pc.OsDiskSize,
+		InstanceClass: pc.InstanceClass,
+		Count:         pc.Count,
+	}}, pc.Months)
+}
+
+func (pc *priceCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*pc), reflect.ValueOf(*pc), reflect.ValueOf(pc), cmd))
+
+	//for completion
+	registerInstanceSizeCompletion(cmd)
+
+	flagName := "instance_class"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validInstanceClassList, cobra.ShellCompDirectiveDefault
+	})
+}
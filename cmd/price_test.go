@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGroupInstanceSizes(t *testing.T) {
+	items := []instanceItem{
+		{InstanceId: "i-1", VcpusCurrent: 2, MemoryCurrent: 4096},
+		{InstanceId: "i-2", InstanceType: "c1m1"},
+		{InstanceId: "i-3", VcpusCurrent: 2, MemoryCurrent: 4096},
+		{InstanceId: "i-4", VcpusCurrent: 2, MemoryCurrent: 4096, InstanceClass: 1},
+	}
+	sizes := groupInstanceSizes(items)
+	expected := []instanceSize{
+		{CPU: 2, Memory: 4096, InstanceClass: "0", Count: 2},
+		{InstanceType: "c1m1", InstanceClass: "0", Count: 1},
+		{CPU: 2, Memory: 4096, InstanceClass: "1", Count: 1},
+	}
+	if len(sizes) != len(expected) {
+		t.Fatal("sizes, got=", sizes, "expected=", expected)
+	}
+	for i, v := range sizes {
+		if v != expected[i] {
+			t.Errorf("sizes[%d], got=%+v, expected=%+v", i, v, expected[i])
+		}
+	}
+}
+
+func TestHourlyCost(t *testing.T) {
+	if cost := hourlyCost(0.5, 0); cost != 0.5 {
+		t.Error("hourly, got=", cost)
+	}
+	if cost := hourlyCost(1440, 2); cost != 1 {
+		t.Error("monthly, got=", cost)
+	}
+}
+
+func TestPriceValue(t *testing.T) {
+	for _, data := range []string{`"0.25"`, `0.25`} {
+		var p priceValue
+		if err := json.Unmarshal([]byte(data), &p); err != nil || p != 0.25 {
+			t.Error(data, "got=", p, err)
+		}
+	}
+}
+
+func TestPriceSequence(t *testing.T) {
+	sizes := []instanceSize{{InstanceType: "c2m4", Count: 3}, {CPU: 4, Memory: 8192, Count: 1}}
+	val := encodeParam(t, newPriceCmd(sizes, 0))
+	if val.Get("resources.1.sequence") != "1" || val.Get("resources.2.sequence") != "2" {
+		t.Error("sequence should start at 1, got=", val)
+	}
+	if val.Get("resources.1.instance_type") != "c2m4" || val.Get("resources.2.cpu") != "4" {
+		t.Error("resources, got=", val)
+	}
+
+	prices, err := sequencePrices([]priceSequence{{Sequence: 2, Price: 0.5}, {Sequence: 1, Price: 0.25}}, 2)
+	if err != nil || prices[0] != 0.25 || prices[1] != 0.5 {
+		t.Error("got=", prices, err)
+	}
+	if _, err := sequencePrices([]priceSequence{{Sequence: 0, Price: 0.25}}, 2); err == nil {
+		t.Error("sequence 0, expected an error")
+	}
+}
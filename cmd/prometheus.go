@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ QingCloudCmd = (*prometheusSdCmd)(nil)
+var _ QingCloudCmd = (*exporterCmd)(nil)
+
+// prometheusLabelEscaper escapes label values of the prometheus text format.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// fileSdGroup is one target group of prometheus file_sd.
+type fileSdGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// fileSdGroups returns a target group of every instance which has an address, labeled by zone, id, name and tags.
+func fileSdGroups(items []instanceItem, zone string, port int64, private bool) []fileSdGroup {
+	groups := []fileSdGroup{}
+	for _, v := range items {
+		addr := inventoryAddress(v, private)
+		if len(addr) == 0 {
+			continue
+		}
+		var tags []string
+		for _, t := range v.Tags {
+			tags = append(tags, t.TagName)
+		}
+		groups = append(groups, fileSdGroup{
+			Targets: []string{fmt.Sprintf("%s:%d", addr, port)},
+			Labels: map[string]string{
+				"zone":          zone,
+				"instance_id":   v.InstanceId,
+				"name":          v.InstanceName,
+				"instance_type": v.InstanceType,
+				"tags":          strings.Join(tags, ","),
+			},
+		})
+	}
+	return groups
+}
+
+// writeFileAtomic writes data to a temporary file then renames it to path,
+// so watchers such as prometheus never read a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+type prometheusSdCmd struct {
+	instanceCmd
+	Port     int64  `name:"port" local:"1" default:"9100" usage:"the port of targets, such as 9100 of node exporter"`
+	Selector string `name:"selector" local:"1" usage:"only export matched instances, such as tag=monitored. Keys are tag, name, status, type, vxnet"`
+	File     string `name:"file" local:"1" usage:"the file_sd json file to write. Default, print the json"`
+	Private  bool   `name:"private" local:"1" default:"false" usage:"use the private ip instead of the eip"`
+}
+
+func (psc *prometheusSdCmd) Send() error {
+	psc.commonParam()
+	items, err := inventoryInstances("", psc.Selector)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fileSdGroups(items, psc.zone, psc.Port, psc.Private), "", "    ")
+	if err != nil {
+		return err
+	}
+	if len(psc.File) == 0 {
+		fmt.Println(string(data))
+		return nil
+	}
+	path, err := homedir.Expand(psc.File)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Println(len(items), "instance(s) written to", path)
+	return nil
+}
+
+func (psc *prometheusSdCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*psc), reflect.ValueOf(*psc), reflect.ValueOf(psc), cmd))
+}
+
+// instanceCountKey is the labels of qingcloud_instances metric.
+type instanceCountKey struct {
+	Zone         string
+	Status       string
+	InstanceType string
+}
+
+// instanceMetrics is the state of exporter, updated by every refresh.
+type instanceMetrics struct {
+	mu          sync.Mutex
+	counts      map[instanceCountKey]int
+	success     bool
+	lastRefresh time.Time
+}
+
+func (m *instanceMetrics) update(counts map[instanceCountKey]int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.success = err == nil
+	//keep the counts of last success, so a transient error does not reset the metrics
+	if err == nil {
+		m.counts = counts
+		m.lastRefresh = time.Now()
+	}
+}
+
+// render returns the metrics in prometheus text format.
+func (m *instanceMetrics) render() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []instanceCountKey
+	for k := range m.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Zone != b.Zone {
+			return a.Zone < b.Zone
+		}
+		if a.Status != b.Status {
+			return a.Status < b.Status
+		}
+		return a.InstanceType < b.InstanceType
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP qingcloud_instances Number of instances by zone, status and instance type.\n")
+	b.WriteString("# TYPE qingcloud_instances gauge\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "qingcloud_instances{zone=\"%s\",status=\"%s\",instance_type=\"%s\"} %d\n",
+			prometheusLabelEscaper.Replace(k.Zone), prometheusLabelEscaper.Replace(k.Status),
+			prometheusLabelEscaper.Replace(k.InstanceType), m.counts[k])
+	}
+	success := 0
+	if m.success {
+		success = 1
+	}
+	b.WriteString("# HELP qingcloud_exporter_refresh_success Whether the last refresh succeeded.\n")
+	b.WriteString("# TYPE qingcloud_exporter_refresh_success gauge\n")
+	fmt.Fprintf(&b, "qingcloud_exporter_refresh_success %d\n", success)
+	b.WriteString("# HELP qingcloud_exporter_last_refresh_timestamp_seconds The time of the last successful refresh.\n")
+	b.WriteString("# TYPE qingcloud_exporter_last_refresh_timestamp_seconds gauge\n")
+	fmt.Fprintf(&b, "qingcloud_exporter_last_refresh_timestamp_seconds %d\n", m.lastRefresh.Unix())
+	return b.String()
+}
+
+// countInstances counts items of zone by status and instance type.
+func countInstances(counts map[instanceCountKey]int, zone string, items []instanceItem) {
+	for _, v := range items {
+		counts[instanceCountKey{Zone: zone, Status: v.Status, InstanceType: v.InstanceType}]++
+	}
+}
+
+type exporterCmd struct {
+	instanceCmd
+	Listen   string   `name:"listen" local:"1" default:":9910" usage:"the address to serve /metrics"`
+	Interval string   `name:"interval" local:"1" default:"1m" usage:"the interval to refresh instances, such as 30s, 5m, at least 10s"`
+	Zones    []string `name:"zones" local:"1" usage:"zone[s] to count instances, default is the current zone. Multiple zones, --zones pek3 --zones sh1a"`
+	Selector string   `name:"selector" local:"1" usage:"only count matched instances, such as tag=monitored. Keys are tag, name, status, type, vxnet"`
+}
+
+// refresh fetches the instances of every zone and counts them.
+func (ec *exporterCmd) refresh() (map[instanceCountKey]int, error) {
+	counts := make(map[instanceCountKey]int)
+	for _, z := range ec.Zones {
+		items, err := inventoryInstances(z, ec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("zone %s, %v", z, err)
+		}
+		countInstances(counts, z, items)
+	}
+	return counts, nil
+}
+
+// minExporterInterval keeps the exporter from calling DescribeInstances too often.
+const minExporterInterval = 10 * time.Second
+
+// parseExporterInterval parses the refresh interval, which must be at least minExporterInterval.
+func parseExporterInterval(s string) (time.Duration, error) {
+	interval, err := parseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if interval < minExporterInterval {
+		return 0, fmt.Errorf("interval %s is too short, must be at least %v", s, minExporterInterval)
+	}
+	return interval, nil
+}
+
+func (ec *exporterCmd) Send() error {
+	interval, err := parseExporterInterval(ec.Interval)
+	if err != nil {
+		return err
+	}
+	if _, err := parseSelector(ec.Selector); err != nil {
+		return err
+	}
+	if len(ec.Zones) == 0 {
+		ec.commonParam()
+		ec.Zones = []string{ec.zone}
+	}
+	for _, z := range ec.Zones {
+		if !validZone(z) {
+			fmt.Println("zone is invalid, must be one of", availableZones(false))
+			os.Exit(0)
+		}
+	}
+
+	metrics := &instanceMetrics{}
+	go func() {
+		for {
+			counts, err := ec.refresh()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "refresh instances failed,", err)
+			}
+			metrics.update(counts, err)
+			time.Sleep(interval)
+		}
+	}()
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, metrics.render())
+	})
+	fmt.Println("serving /metrics on", ec.Listen)
+	return http.ListenAndServe(ec.Listen, nil)
+}
+
+func (ec *exporterCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ec), reflect.ValueOf(*ec), reflect.ValueOf(ec), cmd))
+
+	//for completion
+	flagName := "zones"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return availableZones(false), cobra.ShellCompDirectiveDefault
+	})
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFileSdGroups(t *testing.T) {
+	web := instanceItem{InstanceId: "i-1", InstanceName: "web", InstanceType: "c2m4",
+		Tags: []tagItem{{TagName: "monitored"}, {TagName: "prod"}}, Vxnets: []instanceVxnet{{PrivateIp: "192.168.0.2"}}}
+	groups := fileSdGroups([]instanceItem{web, {InstanceId: "i-2"}}, "pek3", 9100, true)
+	if len(groups) != 1 {
+		t.Fatal("groups, got=", groups)
+	}
+	if groups[0].Targets[0] != "192.168.0.2:9100" {
+		t.Error("target, got=", groups[0].Targets)
+	}
+	labels := groups[0].Labels
+	if labels["zone"] != "pek3" || labels["instance_id"] != "i-1" || labels["name"] != "web" || labels["tags"] != "monitored,prod" {
+		t.Error("labels, got=", labels)
+	}
+}
+
+func TestInstanceMetrics(t *testing.T) {
+	counts := make(map[instanceCountKey]int)
+	countInstances(counts, "pek3", []instanceItem{
+		{Status: "running", InstanceType: "c2m4"},
+		{Status: "running", InstanceType: "c2m4"},
+		{Status: "stopped", InstanceType: "c1m1"},
+	})
+	metrics := &instanceMetrics{}
+	metrics.update(counts, nil)
+	out := metrics.render()
+	for _, line := range []string{
+		`qingcloud_instances{zone="pek3",status="running",instance_type="c2m4"} 2`,
+		`qingcloud_instances{zone="pek3",status="stopped",instance_type="c1m1"} 1`,
+		`qingcloud_exporter_refresh_success 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics missing %s, got=%s", line, out)
+		}
+	}
+
+	//counts of the last success are kept on error
+	metrics.update(nil, errors.New("offline"))
+	out = metrics.render()
+	if !strings.Contains(out, "qingcloud_exporter_refresh_success 0\n") || !strings.Contains(out, `status="running"`) {
+		t.Error("metrics after error, got=", out)
+	}
+}
+
+func TestParseExporterInterval(t *testing.T) {
+	if interval, err := parseExporterInterval("30s"); err != nil || interval.Seconds() != 30 {
+		t.Error("30s, got=", interval, err)
+	}
+	for _, s := range []string{"0s", "-1m", "1s", "x"} {
+		if _, err := parseExporterInterval(s); err == nil {
+			t.Error("should be invalid:", s)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+	"strings"
+)
+
+var validQuotaResourceType = []string{"instance", "cpu", "memory", "volume", "volume_size", "eip", "snapshot", "router", "security_group", "keypair", "loadbalancer"}
+
+func addQuotaCmd(root *cobra.Command) {
+	root.AddCommand(newCommand("describe-quotas", "Fetch the quota left of resources, such as instance, cpu, memory, volume",
+		&describeQuotaCmd{instanceCmd: instanceCmd{action: "GetQuotaLeft"}}))
+}
+
+var _ QingCloudCmd = (*describeQuotaCmd)(nil)
+
+// quotaItem is one element of quota_left_set in GetQuotaLeft response.
+type quotaItem struct {
+	ResourceType string `json:"resource_type"`
+	Left         int64  `json:"left"`
+}
+
+// getQuotaLeft returns the quota left of resourceTypes in zone, keyed by resource type. Empty zone is the current zone.
+func getQuotaLeft(zone string, resourceTypes []string) (map[string]int64, error) {
+	type response struct {
+		QuotaLeftSet []quotaItem `json:"quota_left_set"`
+	}
+	param := &describeQuotaCmd{
+		instanceCmd: instanceCmd{
+			action: "GetQuotaLeft",
+			zone:   zone,
+		},
+		ResourceTypes: resourceTypes,
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	left := make(map[string]int64)
+	for _, v := range resp.QuotaLeftSet {
+		left[v.ResourceType] = v.Left
+	}
+	return left, nil
+}
+
+// instanceTypeSize returns the cpu number and the memory size in MB of instanceType, such as c2m4.
+func instanceTypeSize(instanceType string) (int64, int64, error) {
+	var cpu, memory int64
+	if _, err := fmt.Sscanf(instanceType, "c%dm%d", &cpu, &memory); err != nil {
+		return 0, 0, fmt.Errorf("unknown size of instance type %s", instanceType)
+	}
+	return cpu, memory * 1024, nil
+}
+
+// checkQuota returns an error which lists every resource whose required amount exceeds the quota left.
+// Resources missing in left are not limited.
+func checkQuota(required, left map[string]int64) error {
+	var exceeded []string
+	for _, t := range validQuotaResourceType {
+		need, ok := required[t]
+		if !ok {
+			continue
+		}
+		if l, ok := left[t]; ok && need > l {
+			exceeded = append(exceeded, fmt.Sprintf("%s requires %d, %d left", t, need, l))
+		}
+	}
+	if len(exceeded) != 0 {
+		return errors.New("quota exceeded: " + strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+type describeQuotaCmd struct {
+	instanceCmd
+	ResourceTypes []string `name:"resource_types" usage:"resource type[s] which want to fetch, default is all. Multiple types, --resource_types cpu --resource_types memory"`
+}
+
+func (dqc *describeQuotaCmd) Send() error {
+	for _, t := range dqc.ResourceTypes {
+		if !validParam(validQuotaResourceType, t) {
+			fmt.Println("resource type is invalid, must be one of", validQuotaResourceType)
+			os.Exit(0)
+		}
+	}
+	return dqc.send(dqc)
+}
+
+func (dqc *describeQuotaCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dqc), reflect.ValueOf(*dqc), reflect.ValueOf(dqc), cmd))
+
+	//for completion
+	flagName := "resource_types"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validQuotaResourceType, cobra.ShellCompDirectiveDefault
+	})
+}
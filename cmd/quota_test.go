@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestInstanceTypeSize(t *testing.T) {
+	cpu, memory, err := instanceTypeSize("c2m4")
+	if err != nil || cpu != 2 || memory != 4096 {
+		t.Fatal("c2m4, got=", cpu, memory, err)
+	}
+	if _, _, err := instanceTypeSize("small_b"); err == nil {
+		t.Fatal("unknown instance type, expected an error")
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	left := map[string]int64{"instance": 10, "cpu": 16, "memory": 65536}
+	if err := checkQuota(map[string]int64{"instance": 4, "cpu": 16, "memory": 16384}, left); err != nil {
+		t.Fatal("enough quota, got=", err)
+	}
+
+	err := checkQuota(map[string]int64{"instance": 20, "cpu": 40, "memory": 81920, "volume": 1}, left)
+	if err == nil {
+		t.Fatal("quota exceeded, expected an error")
+	}
+	expected := "quota exceeded: instance requires 20, 10 left; cpu requires 40, 16 left; memory requires 81920, 65536 left"
+	if err.Error() != expected {
+		t.Errorf("got=%s, expected=%s", err.Error(), expected)
+	}
+}
@@ -34,13 +34,33 @@ func init() {
 
 	flagName := "zone"
 	rootCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return validZoneList, cobra.ShellCompDirectiveDefault
+		return availableZones(false), cobra.ShellCompDirectiveDefault
 	})
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(completionCmd)
 	rootCmd.AddCommand(echoDemoCmd)
+	addZoneCmd(rootCmd)
+	addQuotaCmd(rootCmd)
+	addPriceCmd(rootCmd)
 	addInstanceCmd(rootCmd)
+	addVolumeCmd(rootCmd)
+	addVxnetCmd(rootCmd)
+	addRouterCmd(rootCmd)
+	addSecurityGroupCmd(rootCmd)
+	addEipCmd(rootCmd)
+	addKeyPairCmd(rootCmd)
+	addImageCmd(rootCmd)
+	addSnapshotCmd(rootCmd)
+	addLoadBalancerCmd(rootCmd)
+	addTagCmd(rootCmd)
+	addMonitorCmd(rootCmd)
+	addSshCmd(rootCmd)
+	addInventoryCmd(rootCmd)
+	addManifestCmd(rootCmd)
+	addExportCmd(rootCmd)
+	addStackCmd(rootCmd)
+	addTemplateCmd(rootCmd)
 }
 
 func er(msg interface{}) {
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+var validRouterType = []string{"0", "1", "2", "3"}
+var validPortForwardingProtocol = []string{"tcp", "udp"}
+
+// staticTypePortForwarding is the static_type of port forwarding rules in router statics.
+const staticTypePortForwarding = "1"
+
+func addRouterCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "routers",
+		Short: "Manage VPC routers, describe, create, delete router, join or leave vxnet and port forwarding",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch router list, filter by router id, vxnet, status etc.",
+		&describeRouterCmd{instanceCmd: instanceCmd{action: "DescribeRouters"}}))
+	cmd.AddCommand(newCommand("create", "Create one or many routers with the same configuration",
+		&createRouterCmd{instanceCmd: instanceCmd{action: "CreateRouters"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many routers which given router id",
+		&deleteRouterCmd{instanceCmd: instanceCmd{action: "DeleteRouters"}}))
+	cmd.AddCommand(newCommand("join-vxnet", "Connect a vxnet to the router",
+		&joinRouterCmd{instanceCmd: instanceCmd{action: "JoinRouter"}}))
+	cmd.AddCommand(newCommand("leave-vxnet", "Disconnect one or many vxnets from the router",
+		&leaveRouterCmd{instanceCmd: instanceCmd{action: "LeaveRouter"}}))
+	cmd.AddCommand(newCommand("describe-statics", "Fetch the static rules of router, such as port forwarding",
+		&describeRouterStaticCmd{instanceCmd: instanceCmd{action: "DescribeRouterStatics"}}))
+	cmd.AddCommand(newCommand("add-port-forwarding", "Add a port forwarding rule to the router, run apply to take effect",
+		&addPortForwardingCmd{instanceCmd: instanceCmd{action: "AddRouterStatics"}}))
+	cmd.AddCommand(newCommand("delete-port-forwarding", "Delete one or many port forwarding rules, run apply to take effect",
+		&deletePortForwardingCmd{instanceCmd: instanceCmd{action: "DeleteRouterStatics"}}))
+	cmd.AddCommand(newCommand("apply", "Apply the modified configuration of one or many routers",
+		&updateRouterCmd{instanceCmd: instanceCmd{action: "UpdateRouters"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeRouterCmd)(nil)
+var _ QingCloudCmd = (*createRouterCmd)(nil)
+var _ QingCloudCmd = (*deleteRouterCmd)(nil)
+var _ QingCloudCmd = (*joinRouterCmd)(nil)
+var _ QingCloudCmd = (*leaveRouterCmd)(nil)
+var _ QingCloudCmd = (*describeRouterStaticCmd)(nil)
+var _ QingCloudCmd = (*addPortForwardingCmd)(nil)
+var _ QingCloudCmd = (*deletePortForwardingCmd)(nil)
+var _ QingCloudCmd = (*updateRouterCmd)(nil)
+
+// routerItem is one element of router_set in DescribeRouters response.
+type routerItem struct {
+	RouterId   string `json:"router_id"`
+	RouterName string `json:"router_name"`
+	Status     string `json:"status"`
+}
+
+func describeRouters(param *describeRouterCmd) ([]routerItem, error) {
+	type response struct {
+		RouterSet []routerItem `json:"router_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.RouterSet, nil
+}
+
+// registerRouterIdCompletion completes flagName with the router ids, described by name and status.
+func registerRouterIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "routers", func() ([]string, error) {
+		var tmp []string
+		items, err := describeRouters(&describeRouterCmd{
+			instanceCmd: completionInstanceCmd("DescribeRouters"),
+			Limit:       100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range items {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s (%s)", v.RouterId, v.RouterName, v.Status))
+		}
+		return tmp, nil
+	})
+}
+
+type describeRouterCmd struct {
+	instanceCmd
+	RouterIds  []string `name:"routers" usage:"router id[s] which want to fetch. Multiple routers set like --routers rtr1 --routers rtr2"`
+	VxnetId    string   `name:"vxnet" usage:"filter by the vxnet which connected to router"`
+	Status     []string `name:"status" usage:"router status[es] which want to fetch. Multiple status --status st1 --status st2"`
+	SearchWord string   `name:"search_word" usage:"search keyword, router id, name are supported"`
+	Tags       []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Verbose    bool     `name:"verbose" default:"false" usage:"show the vxnets of router or not"`
+	Offset     int64    `name:"offset" default:"0" usage:"matched router offset"`
+	Limit      int64    `name:"limit" default:"20" usage:"matched router limit, default is 20, max is 100"`
+}
+
+func (drc *describeRouterCmd) Send() error {
+	if drc.Limit < 20 || drc.Limit > 100 {
+		drc.Limit = 20
+	}
+	return drc.send(drc)
+}
+
+func (drc *describeRouterCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*drc), reflect.ValueOf(*drc), reflect.ValueOf(drc), cmd))
+
+	//for completion
+	registerRouterIdCompletion(cmd, "routers")
+	registerVxnetIdCompletion(cmd, "vxnet")
+}
+
+type createRouterCmd struct {
+	instanceCmd
+	RouterName    string `name:"router_name" usage:"the router name"`
+	RouterType    string `name:"router_type" default:"1" usage:"router type, 0: medium, 1: small, 2: large, 3: extra-large"`
+	SecurityGroup string `name:"security_group" usage:"security group which router uses"`
+	VpcNetwork    string `name:"vpc_network" usage:"the address range of VPC, 192.168.0.0/16 or 172.16.0.0/16"`
+	Count         int64  `name:"count" usage:"the count of router you want to create with the same configuration"`
+}
+
+func (crc *createRouterCmd) Send() error {
+	if !validParam(validRouterType, crc.RouterType) {
+		fmt.Println("router type is invalid, must be one of", validRouterType)
+		os.Exit(0)
+	}
+	if crc.Count < 1 {
+		crc.Count = 1
+	}
+	return crc.send(crc)
+}
+
+func (crc *createRouterCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*crc), reflect.ValueOf(*crc), reflect.ValueOf(crc), cmd))
+
+	//for completion
+	registerSecurityGroupIdCompletion(cmd, "security_group")
+
+	flagName := "router_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validRouterType, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "vpc_network"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"192.168.0.0/16", "172.16.0.0/16"}, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type deleteRouterCmd struct {
+	instanceCmd
+	RouterIds []string `name:"routers" required:"1" usage:"router id[s] which want to delete. Multiple routers, --routers rtr1 --routers rtr2"`
+}
+
+func (drc *deleteRouterCmd) Send() error {
+	return drc.send(drc)
+}
+
+func (drc *deleteRouterCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*drc), reflect.ValueOf(*drc), reflect.ValueOf(drc), cmd))
+
+	//for completion
+	registerRouterIdCompletion(cmd, "routers")
+}
+
+type joinRouterCmd struct {
+	instanceCmd
+	RouterId   string `name:"router" required:"1" usage:"the router id which vxnet connect to"`
+	VxnetId    string `name:"vxnet" required:"1" usage:"the vxnet id which want to connect"`
+	IpNetwork  string `name:"ip_network" required:"1" usage:"the address range of vxnet, such as 192.168.1.0/24"`
+	Features   string `name:"features" usage:"1: enable DHCP, 0: disable DHCP"`
+	ManagerIp  string `name:"manager_ip" usage:"the manager ip of vxnet, default is x.x.x.1"`
+	DynIpStart string `name:"dyn_ip_start" usage:"the first ip of DHCP range"`
+	DynIpEnd   string `name:"dyn_ip_end" usage:"the last ip of DHCP range"`
+}
+
+func (jrc *joinRouterCmd) Send() error {
+	return jrc.send(jrc)
+}
+
+func (jrc *joinRouterCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*jrc), reflect.ValueOf(*jrc), reflect.ValueOf(jrc), cmd))
+
+	//for completion
+	registerRouterIdCompletion(cmd, "router")
+	registerVxnetIdCompletion(cmd, "vxnet")
+}
+
+type leaveRouterCmd struct {
+	instanceCmd
+	RouterId string   `name:"router" required:"1" usage:"the router id which vxnets disconnect from"`
+	VxnetIds []string `name:"vxnets" required:"1" usage:"vxnet id[s] which want to disconnect. Multiple vxnets, --vxnets vxnet1 --vxnets vxnet2"`
+}
+
+func (lrc *leaveRouterCmd) Send() error {
+	return lrc.send(lrc)
+}
+
+func (lrc *leaveRouterCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*lrc), reflect.ValueOf(*lrc), reflect.ValueOf(lrc), cmd))
+
+	//for completion
+	registerRouterIdCompletion(cmd, "router")
+	registerVxnetIdCompletion(cmd, "vxnets")
+}
+
+type describeRouterStaticCmd struct {
+	instanceCmd
+	RouterId   string `name:"router" required:"1" usage:"the router id which statics belong to"`
+	StaticType string `name:"static_type" usage:"static type, 1: port forwarding, 2: VPN, 3: DHCP, 4: tunnel, 5: filter"`
+	Verbose    bool   `name:"verbose" default:"false" usage:"show detail information or not"`
+	Offset     int64  `name:"offset" default:"0" usage:"matched static offset"`
+	Limit      int64  `name:"limit" default:"20" usage:"matched static limit, default is 20, max is 100"`
+}
+
+func (drc *describeRouterStaticCmd) Send() error {
+	if drc.Limit < 20 || drc.Limit > 100 {
+		drc.Limit = 20
+	}
+	return drc.send(drc)
+}
+
+func (drc *describeRouterStaticCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*drc), reflect.ValueOf(*drc), reflect.ValueOf(drc), cmd))
+
+	//for completion
+	registerRouterIdCompletion(cmd, "router")
+}
+
+type addPortForwardingCmd struct {
+	instanceCmd
+	RouterId   string `name:"router" required:"1" usage:"the router id which rule add to"`
+	StaticName string `name:"static_name" local:"1" usage:"the rule name"`
+	SrcPort    int64  `name:"src_port" local:"1" required:"1" usage:"the source port of router"`
+	DstIp      string `name:"dst_ip" local:"1" required:"1" usage:"the private ip which traffic forward to"`
+	DstPort    int64  `name:"dst_port" local:"1" required:"1" usage:"the port of private ip which traffic forward to"`
+	Protocol   string `name:"protocol" local:"1" default:"tcp" usage:"the protocol, tcp or udp"`
+}
+
+func (apc *addPortForwardingCmd) Send() error {
+	if !validParam(validPortForwardingProtocol, apc.Protocol) {
+		fmt.Println("protocol is invalid, must be one of", validPortForwardingProtocol)
+		os.Exit(0)
+	}
+
+	val := apc.commonParam()
+	mustBeOk(buildUrlValues(reflect.TypeOf(*apc), reflect.ValueOf(*apc), reflect.ValueOf(apc), val))
+	val.Add("statics.1.static_type", staticTypePortForwarding)
+	if len(apc.StaticName) != 0 {
+		val.Add("statics.1.router_static_name", apc.StaticName)
+	}
+	val.Add("statics.1.val1", strconv.Itoa(int(apc.SrcPort)))
+	val.Add("statics.1.val2", apc.DstIp)
+	val.Add("statics.1.val3", strconv.Itoa(int(apc.DstPort)))
+	val.Add("statics.1.val4", apc.Protocol)
+	return sendHttpRequest(val, []byte(apc.qySecretAccessKey))
+}
+
+func (apc *addPortForwardingCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*apc), reflect.ValueOf(*apc), reflect.ValueOf(apc), cmd))
+
+	//for completion
+	registerRouterIdCompletion(cmd, "router")
+
+	flagName := "protocol"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validPortForwardingProtocol, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type deletePortForwardingCmd struct {
+	instanceCmd
+	RouterStaticIds []string `name:"router_statics" required:"1" usage:"rule id[s] which want to delete. Multiple rules, --router_statics rtrs1 --router_statics rtrs2"`
+}
+
+func (dpc *deletePortForwardingCmd) Send() error {
+	return dpc.send(dpc)
+}
+
+func (dpc *deletePortForwardingCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dpc), reflect.ValueOf(*dpc), reflect.ValueOf(dpc), cmd))
+}
+
+type updateRouterCmd struct {
+	instanceCmd
+	RouterIds []string `name:"routers" required:"1" usage:"router id[s] which want to apply. Multiple routers, --routers rtr1 --routers rtr2"`
+}
+
+func (urc *updateRouterCmd) Send() error {
+	return urc.send(urc)
+}
+
+func (urc *updateRouterCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*urc), reflect.ValueOf(*urc), reflect.ValueOf(urc), cmd))
+
+	//for completion
+	registerRouterIdCompletion(cmd, "routers")
+}
@@ -0,0 +1,529 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var validRuleProtocol = []string{"tcp", "udp", "icmp", "gre", "esp", "ah", "ipip"}
+var validRuleAction = []string{"accept", "drop"}
+var validRuleDirection = []string{"0", "1"}
+
+func addSecurityGroupCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "security-groups",
+		Short: "Manage security groups and rules, describe, create, delete, apply security group and sync rules from file",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch security group list, filter by security group id, name etc.",
+		&describeSecurityGroupCmd{instanceCmd: instanceCmd{action: "DescribeSecurityGroups"}}))
+	cmd.AddCommand(newCommand("create", "Create a security group",
+		&createSecurityGroupCmd{instanceCmd: instanceCmd{action: "CreateSecurityGroup"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many security groups which given security group id",
+		&deleteSecurityGroupCmd{instanceCmd: instanceCmd{action: "DeleteSecurityGroups"}}))
+	cmd.AddCommand(newCommand("apply", "Apply the rules of security group to instances",
+		&applySecurityGroupCmd{instanceCmd: instanceCmd{action: "ApplySecurityGroup"}}))
+	cmd.AddCommand(newCommand("describe-rules", "Fetch the rules of security group",
+		&describeSecurityGroupRuleCmd{instanceCmd: instanceCmd{action: "DescribeSecurityGroupRules"}}))
+	cmd.AddCommand(newCommand("add-rule", "Add a rule to security group, run apply to take effect",
+		&addSecurityGroupRuleCmd{instanceCmd: instanceCmd{action: "AddSecurityGroupRules"}}))
+	cmd.AddCommand(newCommand("delete-rules", "Delete one or many rules which given rule id, run apply to take effect",
+		&deleteSecurityGroupRuleCmd{instanceCmd: instanceCmd{action: "DeleteSecurityGroupRules"}}))
+	cmd.AddCommand(newCommand("sync", "Make the rules of security group same as the rules file, and apply the changes",
+		&syncSecurityGroupCmd{}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeSecurityGroupCmd)(nil)
+var _ QingCloudCmd = (*createSecurityGroupCmd)(nil)
+var _ QingCloudCmd = (*deleteSecurityGroupCmd)(nil)
+var _ QingCloudCmd = (*applySecurityGroupCmd)(nil)
+var _ QingCloudCmd = (*describeSecurityGroupRuleCmd)(nil)
+var _ QingCloudCmd = (*addSecurityGroupRuleCmd)(nil)
+var _ QingCloudCmd = (*deleteSecurityGroupRuleCmd)(nil)
+var _ QingCloudCmd = (*syncSecurityGroupCmd)(nil)
+
+// securityGroupItem is one element of security_group_set in DescribeSecurityGroups response.
+type securityGroupItem struct {
+	SecurityGroupId   string    `json:"security_group_id"`
+	SecurityGroupName string    `json:"security_group_name"`
+	IsDefault         int64     `json:"is_default"`
+	Tags              []tagItem `json:"tags"`
+}
+
+func describeSecurityGroups(param *describeSecurityGroupCmd) ([]securityGroupItem, error) {
+	type response struct {
+		SecurityGroupSet []securityGroupItem `json:"security_group_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.SecurityGroupSet, nil
+}
+
+// describeAllSecurityGroups fetches all security groups matched param page by page.
+func describeAllSecurityGroups(param *describeSecurityGroupCmd) ([]securityGroupItem, error) {
+	type response struct {
+		SecurityGroupSet []securityGroupItem `json:"security_group_set"`
+		TotalCount       int64               `json:"total_count"`
+	}
+
+	var items []securityGroupItem
+	for {
+		param.Offset = int64(len(items))
+		param.Limit = 100
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		items = append(items, resp.SecurityGroupSet...)
+		if len(resp.SecurityGroupSet) == 0 || int64(len(items)) >= resp.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+// registerSecurityGroupIdCompletion completes flagName with the security group ids, described by name.
+func registerSecurityGroupIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "security_groups", func() ([]string, error) {
+		var tmp []string
+		items, err := describeSecurityGroups(&describeSecurityGroupCmd{
+			instanceCmd: completionInstanceCmd("DescribeSecurityGroups"),
+			Limit:       100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range items {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s", v.SecurityGroupId, v.SecurityGroupName))
+		}
+		return tmp, nil
+	})
+}
+
+// securityGroupRule is one element of the rules parameter in AddSecurityGroupRules, also the rule of sync file.
+// For icmp, start_port is the icmp type and end_port is the icmp code.
+type securityGroupRule struct {
+	Name      string `name:"security_group_rule_name" yaml:"name"`
+	Protocol  string `name:"protocol" yaml:"protocol"`
+	Priority  string `name:"priority" yaml:"priority"`
+	Action    string `name:"action" yaml:"action"`
+	Direction string `name:"direction" yaml:"direction"`
+	StartPort string `name:"val1" yaml:"start_port"`
+	EndPort   string `name:"val2" yaml:"end_port"`
+	IpNetwork string `name:"val3" yaml:"ip_network"`
+}
+
+// normalize fills the default value of optional fields, so rules can be compared.
+func (r securityGroupRule) normalize() securityGroupRule {
+	r.Protocol = strings.ToLower(r.Protocol)
+	if len(r.Priority) == 0 {
+		r.Priority = "0"
+	}
+	if len(r.Action) == 0 {
+		r.Action = "accept"
+	}
+	if len(r.Direction) == 0 {
+		r.Direction = "0"
+	}
+	return r
+}
+
+// key identifies a rule by all of its fields, a renamed rule is added with the new name and the old one is deleted.
+func (r securityGroupRule) key() string {
+	r = r.normalize()
+	return strings.Join([]string{r.Name, r.Protocol, r.Priority, r.Action, r.Direction, r.StartPort, r.EndPort, r.IpNetwork}, "|")
+}
+
+func (r securityGroupRule) String() string {
+	r = r.normalize()
+	return fmt.Sprintf("direction=%s protocol=%s port=%s-%s ip_network=%s action=%s priority=%s name=%s",
+		r.Direction, r.Protocol, r.StartPort, r.EndPort, r.IpNetwork, r.Action, r.Priority, r.Name)
+}
+
+func checkSecurityGroupRule(r securityGroupRule) {
+	if err := validateSecurityGroupRule(r); err != nil {
+		fmt.Println(err)
+		os.Exit(0)
+	}
+}
+
+// validateSecurityGroupRule checks the normalized rule.
+func validateSecurityGroupRule(r securityGroupRule) error {
+	r = r.normalize()
+	if !validParam(validRuleProtocol, r.Protocol) {
+		return fmt.Errorf("protocol is invalid, must be one of %v", validRuleProtocol)
+	}
+	if !validParam(validRuleAction, r.Action) {
+		return fmt.Errorf("action is invalid, must be one of %v", validRuleAction)
+	}
+	if !validParam(validRuleDirection, r.Direction) {
+		return fmt.Errorf("direction is invalid, must be one of %v", validRuleDirection)
+	}
+	if priority, err := strconv.Atoi(r.Priority); err != nil || priority < 0 || priority > 100 {
+		return errors.New("priority is invalid, must be between 0 and 100")
+	}
+	return nil
+}
+
+// securityGroupRuleItem is one element of security_group_rule_set in DescribeSecurityGroupRules response.
+type securityGroupRuleItem struct {
+	SecurityGroupRuleId   string `json:"security_group_rule_id"`
+	SecurityGroupRuleName string `json:"security_group_rule_name"`
+	Protocol              string `json:"protocol"`
+	Priority              int64  `json:"priority"`
+	Action                string `json:"action"`
+	Direction             int64  `json:"direction"`
+	Val1                  string `json:"val1"`
+	Val2                  string `json:"val2"`
+	Val3                  string `json:"val3"`
+}
+
+func (item securityGroupRuleItem) rule() securityGroupRule {
+	return securityGroupRule{
+		Name:      item.SecurityGroupRuleName,
+		Protocol:  item.Protocol,
+		Priority:  strconv.Itoa(int(item.Priority)),
+		Action:    item.Action,
+		Direction: strconv.Itoa(int(item.Direction)),
+		StartPort: item.Val1,
+		EndPort:   item.Val2,
+		IpNetwork: item.Val3,
+	}
+}
+
+// describeAllSecurityGroupRules fetches all rules of the security group page by page.
+func describeAllSecurityGroupRules(securityGroupId string) ([]securityGroupRuleItem, error) {
+	type response struct {
+		SecurityGroupRuleSet []securityGroupRuleItem `json:"security_group_rule_set"`
+		TotalCount           int64                   `json:"total_count"`
+	}
+
+	var items []securityGroupRuleItem
+	for {
+		param := &describeSecurityGroupRuleCmd{
+			instanceCmd: instanceCmd{
+				action: "DescribeSecurityGroupRules",
+			},
+			SecurityGroupId: securityGroupId,
+			Offset:          int64(len(items)),
+			Limit:           100,
+		}
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		items = append(items, resp.SecurityGroupRuleSet...)
+		if len(resp.SecurityGroupRuleSet) == 0 || int64(len(items)) >= resp.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+// diffSecurityGroupRules returns the rules in desired but not in current, and the current rules not in desired.
+func diffSecurityGroupRules(current []securityGroupRuleItem, desired []securityGroupRule) ([]securityGroupRule, []securityGroupRuleItem) {
+	currentKeys := make(map[string]bool)
+	for _, item := range current {
+		currentKeys[item.rule().key()] = true
+	}
+	desiredKeys := make(map[string]bool)
+	var toAdd []securityGroupRule
+	for _, r := range desired {
+		key := r.key()
+		if !currentKeys[key] && !desiredKeys[key] {
+			toAdd = append(toAdd, r.normalize())
+		}
+		desiredKeys[key] = true
+	}
+
+	var toDelete []securityGroupRuleItem
+	for _, item := range current {
+		if !desiredKeys[item.rule().key()] {
+			toDelete = append(toDelete, item)
+		}
+	}
+	return toAdd, toDelete
+}
+
+type describeSecurityGroupCmd struct {
+	instanceCmd
+	SecurityGroupIds  []string `name:"security_groups" usage:"security group id[s] which want to fetch. Multiple security groups set like --security_groups sg1 --security_groups sg2"`
+	SecurityGroupName string   `name:"security_group_name" usage:"filter by security group name"`
+	SearchWord        string   `name:"search_word" usage:"search keyword, security group id, name are supported"`
+	Tags              []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Verbose           bool     `name:"verbose" default:"false" usage:"show the instances of security group or not"`
+	Offset            int64    `name:"offset" default:"0" usage:"matched security group offset"`
+	Limit             int64    `name:"limit" default:"20" usage:"matched security group limit, default is 20, max is 100"`
+}
+
+func (dsc *describeSecurityGroupCmd) Send() error {
+	if dsc.Limit < 20 || dsc.Limit > 100 {
+		dsc.Limit = 20
+	}
+	return dsc.send(dsc)
+}
+
+func (dsc *describeSecurityGroupCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+
+	//for completion
+	registerSecurityGroupIdCompletion(cmd, "security_groups")
+}
+
+type createSecurityGroupCmd struct {
+	instanceCmd
+	SecurityGroupName string `name:"security_group_name" usage:"the security group name"`
+}
+
+func (csc *createSecurityGroupCmd) Send() error {
+	return csc.send(csc)
+}
+
+func (csc *createSecurityGroupCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*csc), reflect.ValueOf(*csc), reflect.ValueOf(csc), cmd))
+}
+
+type deleteSecurityGroupCmd struct {
+	instanceCmd
+	SecurityGroupIds []string `name:"security_groups" required:"1" usage:"security group id[s] which want to delete. Multiple security groups, --security_groups sg1 --security_groups sg2"`
+}
+
+func (dsc *deleteSecurityGroupCmd) Send() error {
+	return dsc.send(dsc)
+}
+
+func (dsc *deleteSecurityGroupCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+
+	//for completion
+	registerSecurityGroupIdCompletion(cmd, "security_groups")
+}
+
+type applySecurityGroupCmd struct {
+	instanceCmd
+	SecurityGroupId string   `name:"security_group" required:"1" usage:"the security group id which want to apply"`
+	InstanceIds     []string `name:"instances" usage:"instance id[s] which the security group apply to, default is all instances of the security group"`
+}
+
+func (asc *applySecurityGroupCmd) Send() error {
+	return asc.send(asc)
+}
+
+func (asc *applySecurityGroupCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*asc), reflect.ValueOf(*asc), reflect.ValueOf(asc), cmd))
+
+	//for completion
+	registerSecurityGroupIdCompletion(cmd, "security_group")
+	registerInstanceIdCompletion(cmd, "instances")
+}
+
+type describeSecurityGroupRuleCmd struct {
+	instanceCmd
+	SecurityGroupId      string   `name:"security_group" usage:"the security group id which rules belong to"`
+	SecurityGroupRuleIds []string `name:"security_group_rules" usage:"rule id[s] which want to fetch. Multiple rules set like --security_group_rules sgr1 --security_group_rules sgr2"`
+	Direction            string   `name:"direction" usage:"rule direction, 0: inbound, 1: outbound"`
+	Offset               int64    `name:"offset" default:"0" usage:"matched rule offset"`
+	Limit                int64    `name:"limit" default:"20" usage:"matched rule limit, default is 20, max is 100"`
+}
+
+func (dsc *describeSecurityGroupRuleCmd) Send() error {
+	if len(dsc.Direction) != 0 && !validParam(validRuleDirection, dsc.Direction) {
+		fmt.Println("direction is invalid, must be one of", validRuleDirection)
+		os.Exit(0)
+	}
+	if dsc.Limit < 20 || dsc.Limit > 100 {
+		dsc.Limit = 20
+	}
+	return dsc.send(dsc)
+}
+
+func (dsc *describeSecurityGroupRuleCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+
+	//for completion
+	registerSecurityGroupIdCompletion(cmd, "security_group")
+
+	flagName := "direction"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validRuleDirection, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type addSecurityGroupRuleCmd struct {
+	instanceCmd
+	SecurityGroupId string              `name:"security_group" required:"1" usage:"the security group id which rule add to"`
+	Rules           []securityGroupRule `name:"rules"`
+	Name            string              `name:"rule_name" local:"1" usage:"the rule name"`
+	Protocol        string              `name:"protocol" local:"1" required:"1" usage:"the protocol, tcp, udp, icmp, gre, esp, ah, ipip"`
+	Priority        string              `name:"priority" local:"1" default:"0" usage:"the priority between 0 and 100, 0 is the highest"`
+	Action          string              `name:"action" local:"1" default:"accept" usage:"accept or drop"`
+	Direction       string              `name:"direction" local:"1" default:"0" usage:"rule direction, 0: inbound, 1: outbound"`
+	StartPort       string              `name:"start_port" local:"1" usage:"the start port for tcp and udp, the icmp type for icmp"`
+	EndPort         string              `name:"end_port" local:"1" usage:"the end port for tcp and udp, the icmp code for icmp"`
+	IpNetwork       string              `name:"ip_network" local:"1" usage:"the source ip network for inbound, the destination for outbound, such as 10.0.0.0/8"`
+}
+
+func (asc *addSecurityGroupRuleCmd) Send() error {
+	rule := securityGroupRule{
+		Name:      asc.Name,
+		Protocol:  asc.Protocol,
+		Priority:  asc.Priority,
+		Action:    asc.Action,
+		Direction: asc.Direction,
+		StartPort: asc.StartPort,
+		EndPort:   asc.EndPort,
+		IpNetwork: asc.IpNetwork,
+	}
+	checkSecurityGroupRule(rule)
+	asc.Rules = []securityGroupRule{rule.normalize()}
+	return asc.send(asc)
+}
+
+func (asc *addSecurityGroupRuleCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*asc), reflect.ValueOf(*asc), reflect.ValueOf(asc), cmd))
+
+	//for completion
+	registerSecurityGroupIdCompletion(cmd, "security_group")
+
+	flagName := "protocol"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validRuleProtocol, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "action"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validRuleAction, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "direction"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validRuleDirection, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type deleteSecurityGroupRuleCmd struct {
+	instanceCmd
+	SecurityGroupRuleIds []string `name:"security_group_rules" required:"1" usage:"rule id[s] which want to delete. Multiple rules, --security_group_rules sgr1 --security_group_rules sgr2"`
+}
+
+func (dsc *deleteSecurityGroupRuleCmd) Send() error {
+	return dsc.send(dsc)
+}
+
+func (dsc *deleteSecurityGroupRuleCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+}
+
+// securityGroupRuleFile is the content of the rules file used by sync.
+type securityGroupRuleFile struct {
+	SecurityGroup string              `yaml:"security_group"`
+	Rules         []securityGroupRule `yaml:"rules"`
+}
+
+type syncSecurityGroupCmd struct {
+	instanceCmd
+	File            string `name:"file" local:"1" required:"1" usage:"the yaml file of desired rules"`
+	SecurityGroupId string `name:"security_group" local:"1" usage:"the security group id which want to sync, overwrite the value of file"`
+	DryRun          bool   `name:"dry-run" local:"1" default:"false" usage:"only print the changes, do not apply them"`
+}
+
+func (ssc *syncSecurityGroupCmd) Send() error {
+	data, err := ioutil.ReadFile(ssc.File)
+	if err != nil {
+		return err
+	}
+	file := securityGroupRuleFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	if len(ssc.SecurityGroupId) != 0 {
+		file.SecurityGroup = ssc.SecurityGroupId
+	}
+	if len(file.SecurityGroup) == 0 {
+		fmt.Println("security group must be specified by --security_group or in the rules file")
+		os.Exit(0)
+	}
+	for _, r := range file.Rules {
+		checkSecurityGroupRule(r)
+	}
+
+	current, err := describeAllSecurityGroupRules(file.SecurityGroup)
+	if err != nil {
+		return err
+	}
+	toAdd, toDelete := diffSecurityGroupRules(current, file.Rules)
+	for _, item := range toDelete {
+		fmt.Println("-", item.SecurityGroupRuleId, item.rule())
+	}
+	for _, r := range toAdd {
+		fmt.Println("+", r)
+	}
+	if len(toAdd) == 0 && len(toDelete) == 0 {
+		fmt.Println("rules are up to date")
+		return nil
+	}
+	if ssc.DryRun {
+		return nil
+	}
+
+	//add the new rules before deleting the old ones, so the group never loses the rules which allow access
+	if len(toAdd) != 0 {
+		param := &addSecurityGroupRuleCmd{
+			instanceCmd: instanceCmd{
+				action: "AddSecurityGroupRules",
+			},
+			SecurityGroupId: file.SecurityGroup,
+			Rules:           toAdd,
+		}
+		if err := param.request(param, nil); err != nil {
+			return err
+		}
+	}
+
+	if len(toDelete) != 0 {
+		param := &deleteSecurityGroupRuleCmd{
+			instanceCmd: instanceCmd{
+				action: "DeleteSecurityGroupRules",
+			},
+		}
+		for _, item := range toDelete {
+			param.SecurityGroupRuleIds = append(param.SecurityGroupRuleIds, item.SecurityGroupRuleId)
+		}
+		if err := param.request(param, nil); err != nil {
+			return err
+		}
+	}
+
+	apply := &applySecurityGroupCmd{
+		instanceCmd: instanceCmd{
+			action: "ApplySecurityGroup",
+		},
+		SecurityGroupId: file.SecurityGroup,
+	}
+	resp := apiResponse{}
+	if err := apply.request(apply, &resp); err != nil {
+		return err
+	}
+	if len(resp.JobId) != 0 {
+		if err := waitJob(resp.JobId); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d rule(s) added, %d rule(s) deleted, security group %s applied\n", len(toAdd), len(toDelete), file.SecurityGroup)
+	return nil
+}
+
+func (ssc *syncSecurityGroupCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ssc), reflect.ValueOf(*ssc), reflect.ValueOf(ssc), cmd))
+
+	//for completion
+	registerSecurityGroupIdCompletion(cmd, "security_group")
+}
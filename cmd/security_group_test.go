@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"gopkg.in/yaml.v2"
+	"testing"
+)
+
+func TestDiffSecurityGroupRules(t *testing.T) {
+	data := []byte(`
+security_group: sg-1
+rules:
+  - name: ssh
+    protocol: tcp
+    priority: 1
+    start_port: 22
+    end_port: 22
+  - name: https
+    protocol: TCP
+    start_port: 443
+    end_port: 443
+    ip_network: 0.0.0.0/0
+`)
+	file := securityGroupRuleFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.SecurityGroup != "sg-1" || len(file.Rules) != 2 {
+		t.Fatal("unexpected rules file", file)
+	}
+
+	current := []securityGroupRuleItem{
+		{SecurityGroupRuleId: "sgr-ssh", SecurityGroupRuleName: "old name", Protocol: "tcp", Priority: 1, Action: "accept", Val1: "22", Val2: "22"},
+		{SecurityGroupRuleId: "sgr-ping", Protocol: "icmp", Priority: 0, Action: "accept", Val1: "8", Val2: "0"},
+	}
+	toAdd, toDelete := diffSecurityGroupRules(current, file.Rules)
+
+	if len(toAdd) != 2 || toAdd[0].Name != "ssh" || toAdd[1].Name != "https" {
+		t.Error("to add, got=", toAdd, "expected= ssh https")
+	} else if toAdd[1].Protocol != "tcp" || toAdd[1].Direction != "0" || toAdd[1].Action != "accept" {
+		t.Error("rule should be normalized, got=", toAdd[1])
+	}
+	if len(toDelete) != 2 || toDelete[0].SecurityGroupRuleId != "sgr-ssh" || toDelete[1].SecurityGroupRuleId != "sgr-ping" {
+		t.Error("to delete, got=", toDelete, "expected= sgr-ssh sgr-ping")
+	}
+
+	current[0].SecurityGroupRuleName = "ssh"
+	toAdd, toDelete = diffSecurityGroupRules(current, file.Rules)
+	if len(toAdd) != 1 || toAdd[0].Name != "https" || len(toDelete) != 1 || toDelete[0].SecurityGroupRuleId != "sgr-ping" {
+		t.Error("same name, got=", toAdd, toDelete)
+	}
+
+	toAdd, toDelete = diffSecurityGroupRules(nil, append(file.Rules, file.Rules[0]))
+	if len(toAdd) != 2 || len(toDelete) != 0 {
+		t.Error("duplicated rules should be added once, got=", toAdd)
+	}
+}
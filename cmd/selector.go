@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tagItem is one element of the tags attached to a resource in describe responses.
+type tagItem struct {
+	TagId   string `json:"tag_id"`
+	TagName string `json:"tag_name"`
+}
+
+// tagValues returns both the ids and names of tags, so selector can match either of them.
+func tagValues(tags []tagItem) []string {
+	var values []string
+	for _, t := range tags {
+		values = append(values, t.TagId, t.TagName)
+	}
+	return values
+}
+
+type selectorTerm struct {
+	key   string
+	value string
+}
+
+// selector filters resources by comma separated key=value pairs, such as tag=backup,status=running.
+// A resource matches when every pair matches one of the values of its key.
+type selector []selectorTerm
+
+func parseSelector(s string) (selector, error) {
+	var sel selector
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid selector %s, expected format is key=value[,key=value]", pair)
+		}
+		sel = append(sel, selectorTerm{key: strings.TrimSpace(kv[0]), value: strings.TrimSpace(kv[1])})
+	}
+	return sel, nil
+}
+
+func (sel selector) match(fields map[string][]string) bool {
+	for _, term := range sel {
+		if !validParam(fields[term.key], term.value) {
+			return false
+		}
+	}
+	return true
+}
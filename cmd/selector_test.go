@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	sel, err := parseSelector("tag=backup, status=available")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel) != 2 {
+		t.Fatal("selector terms, got=", len(sel), "expected=", 2)
+	}
+
+	fields := map[string][]string{
+		"tag":    tagValues([]tagItem{{TagId: "tag-1", TagName: "backup"}}),
+		"status": {"available"},
+	}
+	if !sel.match(fields) {
+		t.Error("should match", fields)
+	}
+	fields["status"] = []string{"pending"}
+	if sel.match(fields) {
+		t.Error("should not match", fields)
+	}
+
+	empty, err := parseSelector("")
+	if err != nil || !empty.match(nil) {
+		t.Error("empty selector should match everything")
+	}
+
+	for _, v := range []string{"tag", "tag=", "=backup"} {
+		if _, err := parseSelector(v); err == nil {
+			t.Error("should be invalid:", v)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+)
+
+var validSnapshotStatus = []string{"pending", "available", "suspended", "deleted", "ceased"}
+
+func addSnapshotCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "snapshots",
+		Short: "Manage snapshots of instances and volumes, describe, create, delete, apply, capture image and prune",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch snapshot list, filter by snapshot id, resource, status etc.",
+		&describeSnapshotCmd{instanceCmd: instanceCmd{action: "DescribeSnapshots"}}))
+	cmd.AddCommand(newCommand("create", "Create snapshots of one or many instances or volumes",
+		&createSnapshotCmd{instanceCmd: instanceCmd{action: "CreateSnapshots"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many snapshots which given snapshot id",
+		&deleteSnapshotCmd{instanceCmd: instanceCmd{action: "DeleteSnapshots"}}))
+	cmd.AddCommand(newCommand("apply", "Restore the instances or volumes to one or many snapshots",
+		&applySnapshotCmd{instanceCmd: instanceCmd{action: "ApplySnapshots"}}))
+	cmd.AddCommand(newCommand("capture-image", "Capture an image from an instance snapshot",
+		&captureImageFromSnapshotCmd{instanceCmd: instanceCmd{action: "CaptureInstanceFromSnapshot"}}))
+	cmd.AddCommand(newCommand("prune", "Delete old snapshots, keep the newest ones of each resource",
+		&pruneSnapshotCmd{}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeSnapshotCmd)(nil)
+var _ QingCloudCmd = (*createSnapshotCmd)(nil)
+var _ QingCloudCmd = (*deleteSnapshotCmd)(nil)
+var _ QingCloudCmd = (*applySnapshotCmd)(nil)
+var _ QingCloudCmd = (*captureImageFromSnapshotCmd)(nil)
+var _ QingCloudCmd = (*pruneSnapshotCmd)(nil)
+
+// snapshotItem is one element of snapshot_set in DescribeSnapshots response.
+type snapshotItem struct {
+	SnapshotId   string    `json:"snapshot_id"`
+	SnapshotName string    `json:"snapshot_name"`
+	Status       string    `json:"status"`
+	CreateTime   time.Time `json:"create_time"`
+	SnapshotType int64     `json:"snapshot_type"` //0: incremental, 1: full
+	RootId       string    `json:"root_id"`       //the full snapshot of the chain
+	ParentId     string    `json:"parent_id"`
+	Resource     struct {
+		ResourceId   string `json:"resource_id"`
+		ResourceName string `json:"resource_name"`
+		ResourceType string `json:"resource_type"`
+	} `json:"resource"`
+	Tags []tagItem `json:"tags"`
+}
+
+func (item snapshotItem) fields() map[string][]string {
+	return map[string][]string{
+		"tag":      tagValues(item.Tags),
+		"name":     {item.SnapshotName},
+		"status":   {item.Status},
+		"resource": {item.Resource.ResourceId, item.Resource.ResourceName},
+	}
+}
+
+// describeAllSnapshots fetches all snapshots matched param page by page.
+func describeAllSnapshots(param *describeSnapshotCmd) ([]snapshotItem, error) {
+	type response struct {
+		SnapshotSet []snapshotItem `json:"snapshot_set"`
+		TotalCount  int64          `json:"total_count"`
+	}
+
+	var items []snapshotItem
+	for {
+		param.Offset = int64(len(items))
+		param.Limit = 100
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		items = append(items, resp.SnapshotSet...)
+		if len(resp.SnapshotSet) == 0 || int64(len(items)) >= resp.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+// registerSnapshotIdCompletion completes flagName with the snapshot ids, described by name, resource and status.
+func registerSnapshotIdCompletion(cmd *cobra.Command, flagName string) {
+	type response struct {
+		SnapshotSet []snapshotItem `json:"snapshot_set"`
+	}
+
+	registerCachedCompletion(cmd, flagName, "snapshots", func() ([]string, error) {
+		var tmp []string
+		param := &describeSnapshotCmd{
+			instanceCmd: completionInstanceCmd("DescribeSnapshots"),
+			Limit:       100,
+		}
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		for _, v := range resp.SnapshotSet {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s of %s (%s)", v.SnapshotId, v.SnapshotName, v.Resource.ResourceId, v.Status))
+		}
+		return tmp, nil
+	})
+}
+
+// selectSnapshotsToPrune keeps the newest keepLast snapshots of each resource,
+// and returns the others which are older than olderThan. Zero olderThan means no age limit.
+func selectSnapshotsToPrune(items []snapshotItem, keepLast int, olderThan time.Duration, now time.Time) []snapshotItem {
+	byResource := make(map[string][]snapshotItem)
+	var resourceIds []string
+	for _, v := range items {
+		if _, ok := byResource[v.Resource.ResourceId]; !ok {
+			resourceIds = append(resourceIds, v.Resource.ResourceId)
+		}
+		byResource[v.Resource.ResourceId] = append(byResource[v.Resource.ResourceId], v)
+	}
+
+	var pruned []snapshotItem
+	for _, id := range resourceIds {
+		list := byResource[id]
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].CreateTime.After(list[j].CreateTime)
+		})
+		for i, v := range list {
+			if i < keepLast {
+				continue
+			}
+			if olderThan > 0 && now.Sub(v.CreateTime) < olderThan {
+				continue
+			}
+			pruned = append(pruned, v)
+		}
+	}
+	return pruned
+}
+
+// snapshotDependencies returns the ids of the snapshots which item depends on, its parents up to
+// the full snapshot of the chain. Deleting any of them would delete item too.
+func snapshotDependencies(item snapshotItem, byId map[string]snapshotItem) []string {
+	var ids []string
+	seen := map[string]bool{item.SnapshotId: true}
+	for id := item.ParentId; len(id) != 0 && !seen[id]; {
+		seen[id] = true
+		ids = append(ids, id)
+		parent, ok := byId[id]
+		if !ok {
+			break
+		}
+		id = parent.ParentId
+	}
+	if len(item.RootId) != 0 && !seen[item.RootId] {
+		ids = append(ids, item.RootId)
+	}
+	return ids
+}
+
+// keepSnapshotChains removes the snapshots which a kept snapshot of all depends on from pruned,
+// so pruning never deletes the chain of a kept incremental snapshot. The removed ones are returned too.
+func keepSnapshotChains(pruned, all []snapshotItem) ([]snapshotItem, []snapshotItem) {
+	byId := make(map[string]snapshotItem)
+	prunedIds := make(map[string]bool)
+	for _, v := range all {
+		byId[v.SnapshotId] = v
+	}
+	for _, v := range pruned {
+		prunedIds[v.SnapshotId] = true
+	}
+	needed := make(map[string]bool)
+	for _, v := range all {
+		if prunedIds[v.SnapshotId] {
+			continue
+		}
+		for _, id := range snapshotDependencies(v, byId) {
+			needed[id] = true
+		}
+	}
+
+	var kept, protected []snapshotItem
+	for _, v := range pruned {
+		if needed[v.SnapshotId] {
+			protected = append(protected, v)
+		} else {
+			kept = append(kept, v)
+		}
+	}
+	return kept, protected
+}
+
+type describeSnapshotCmd struct {
+	instanceCmd
+	SnapshotIds  []string `name:"snapshots" usage:"snapshot id[s] which want to fetch. Multiple snapshots set like --snapshots ss1 --snapshots ss2"`
+	ResourceId   string   `name:"resource_id" usage:"filter by the instance or volume id which snapshot belongs to"`
+	SnapshotType string   `name:"snapshot_type" usage:"snapshot type, 0: incremental, 1: full"`
+	Status       []string `name:"status" usage:"snapshot status[es] which want to fetch. Multiple status --status st1 --status st2"`
+	SearchWord   string   `name:"search_word" usage:"search keyword, snapshot id, name are supported"`
+	Tags         []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Verbose      bool     `name:"verbose" default:"false" usage:"show detail information or not"`
+	Offset       int64    `name:"offset" default:"0" usage:"matched snapshot offset"`
+	Limit        int64    `name:"limit" default:"20" usage:"matched snapshot limit, default is 20, max is 100"`
+}
+
+func (dsc *describeSnapshotCmd) Send() error {
+	if len(dsc.SnapshotType) != 0 && dsc.SnapshotType != "0" && dsc.SnapshotType != "1" {
+		fmt.Println("snapshot type must be 0 or 1")
+		os.Exit(0)
+	}
+	if dsc.Limit < 20 || dsc.Limit > 100 {
+		dsc.Limit = 20
+	}
+	return dsc.send(dsc)
+}
+
+func (dsc *describeSnapshotCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+
+	//for completion
+	registerSnapshotIdCompletion(cmd, "snapshots")
+
+	flagName := "snapshot_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"0", "1"}, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "status"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validSnapshotStatus, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type createSnapshotCmd struct {
+	instanceCmd
+	ResourceIds  []string `name:"resources" required:"1" usage:"instance or volume id[s] which want to snapshot. Multiple resources, --resources ins1 --resources vol1"`
+	SnapshotName string   `name:"snapshot_name" usage:"the snapshot name"`
+	IsFull       bool     `name:"is_full" default:"false" usage:"create a full snapshot or an incremental one"`
+}
+
+func (csc *createSnapshotCmd) Send() error {
+	return csc.send(csc)
+}
+
+func (csc *createSnapshotCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*csc), reflect.ValueOf(*csc), reflect.ValueOf(csc), cmd))
+}
+
+type deleteSnapshotCmd struct {
+	instanceCmd
+	SnapshotIds []string `name:"snapshots" required:"1" usage:"snapshot id[s] which want to delete. Multiple snapshots, --snapshots ss1 --snapshots ss2"`
+}
+
+func (dsc *deleteSnapshotCmd) Send() error {
+	return dsc.send(dsc)
+}
+
+func (dsc *deleteSnapshotCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dsc), reflect.ValueOf(*dsc), reflect.ValueOf(dsc), cmd))
+
+	//for completion
+	registerSnapshotIdCompletion(cmd, "snapshots")
+}
+
+type applySnapshotCmd struct {
+	instanceCmd
+	SnapshotIds []string `name:"snapshots" required:"1" usage:"snapshot id[s] which want to apply. Multiple snapshots, --snapshots ss1 --snapshots ss2"`
+}
+
+func (asc *applySnapshotCmd) Send() error {
+	return asc.send(asc)
+}
+
+func (asc *applySnapshotCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*asc), reflect.ValueOf(*asc), reflect.ValueOf(asc), cmd))
+
+	//for completion
+	registerSnapshotIdCompletion(cmd, "snapshots")
+}
+
+type captureImageFromSnapshotCmd struct {
+	instanceCmd
+	SnapshotId string `name:"snapshot" required:"1" usage:"the instance snapshot id which want to capture"`
+	ImageName  string `name:"image_name" usage:"the new image name"`
+}
+
+func (csc *captureImageFromSnapshotCmd) Send() error {
+	return csc.send(csc)
+}
+
+func (csc *captureImageFromSnapshotCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*csc), reflect.ValueOf(*csc), reflect.ValueOf(csc), cmd))
+
+	//for completion
+	registerSnapshotIdCompletion(cmd, "snapshot")
+}
+
+type pruneSnapshotCmd struct {
+	instanceCmd
+	KeepLast  int64  `name:"keep-last" local:"1" default:"0" usage:"the count of newest snapshots to keep for each resource"`
+	OlderThan string `name:"older-than" local:"1" usage:"only delete snapshots older than this, such as 30d, 12h"`
+	Selector  string `name:"selector" local:"1" usage:"only prune matched snapshots, such as tag=backup,resource=vol-xxx. Keys are tag, name, status, resource"`
+	Yes       bool   `name:"yes" local:"1" default:"false" usage:"delete without confirmation"`
+}
+
+func (psc *pruneSnapshotCmd) Send() error {
+	if psc.KeepLast < 0 {
+		fmt.Println("keep-last must not be negative")
+		os.Exit(0)
+	}
+	var olderThan time.Duration
+	if len(psc.OlderThan) != 0 {
+		d, err := parseDuration(psc.OlderThan)
+		if err != nil {
+			return err
+		}
+		olderThan = d
+	}
+	if psc.KeepLast == 0 && olderThan == 0 {
+		fmt.Println("at least one of keep-last and older-than must be specified")
+		os.Exit(0)
+	}
+	sel, err := parseSelector(psc.Selector)
+	if err != nil {
+		return err
+	}
+
+	//snapshots which are not matched or not available may depend on the matched ones too
+	items, err := describeAllSnapshots(&describeSnapshotCmd{
+		instanceCmd: instanceCmd{
+			action: "DescribeSnapshots",
+		},
+		Status: []string{"pending", "available", "suspended"},
+	})
+	if err != nil {
+		return err
+	}
+	var matched []snapshotItem
+	for _, v := range items {
+		if v.Status == "available" && sel.match(v.fields()) {
+			matched = append(matched, v)
+		}
+	}
+
+	pruned := selectSnapshotsToPrune(matched, int(psc.KeepLast), olderThan, time.Now())
+	pruned, protected := keepSnapshotChains(pruned, items)
+	for _, v := range protected {
+		fmt.Println("keep", v.SnapshotId, "because the kept snapshots depend on it")
+	}
+	if len(pruned) == 0 {
+		fmt.Println("no snapshot to prune")
+		return nil
+	}
+	param := &deleteSnapshotCmd{
+		instanceCmd: instanceCmd{
+			action: "DeleteSnapshots",
+		},
+	}
+	for _, v := range pruned {
+		fmt.Printf("%s\t%s\t%s\t%s\n", v.SnapshotId, v.SnapshotName, v.Resource.ResourceId, v.CreateTime.Format(time.RFC3339))
+		param.SnapshotIds = append(param.SnapshotIds, v.SnapshotId)
+	}
+	if !psc.Yes && !confirm(fmt.Sprintf("Delete the %d snapshot(s) above?", len(pruned))) {
+		fmt.Println("canceled")
+		return nil
+	}
+	return param.send(param)
+}
+
+func (psc *pruneSnapshotCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*psc), reflect.ValueOf(*psc), reflect.ValueOf(psc), cmd))
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectSnapshotsToPrune(t *testing.T) {
+	now := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	newSnapshot := func(id, resourceId string, age time.Duration) snapshotItem {
+		item := snapshotItem{SnapshotId: id, CreateTime: now.Add(-age)}
+		item.Resource.ResourceId = resourceId
+		return item
+	}
+	day := 24 * time.Hour
+	items := []snapshotItem{
+		newSnapshot("ss-a1", "vol-a", 40*day),
+		newSnapshot("ss-a2", "vol-a", 1*day),
+		newSnapshot("ss-a3", "vol-a", 31*day),
+		newSnapshot("ss-a4", "vol-a", 10*day),
+		newSnapshot("ss-b1", "vol-b", 60*day),
+	}
+
+	pruned := selectSnapshotsToPrune(items, 2, 30*day, now)
+	expected := []string{"ss-a3", "ss-a1"}
+	if len(pruned) != len(expected) {
+		t.Fatal("pruned, got=", pruned, "expected=", expected)
+	}
+	for i, v := range pruned {
+		if v.SnapshotId != expected[i] {
+			t.Errorf("pruned[%d], got=%s, expected=%s", i, v.SnapshotId, expected[i])
+		}
+	}
+
+	pruned = selectSnapshotsToPrune(items, 3, 0, now)
+	if len(pruned) != 1 || pruned[0].SnapshotId != "ss-a1" {
+		t.Error("pruned, got=", pruned, "expected= ss-a1")
+	}
+}
+
+func TestKeepSnapshotChains(t *testing.T) {
+	now := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	newSnapshot := func(id, parentId string, age time.Duration) snapshotItem {
+		item := snapshotItem{SnapshotId: id, ParentId: parentId, CreateTime: now.Add(-age)}
+		if len(parentId) != 0 {
+			item.RootId = "ss-full"
+		} else {
+			item.SnapshotType = 1
+		}
+		item.Resource.ResourceId = "vol-a"
+		return item
+	}
+	//the three newest are incrementals on top of an old full snapshot
+	items := []snapshotItem{
+		newSnapshot("ss-full", "", 90*day),
+		newSnapshot("ss-inc1", "ss-full", 60*day),
+		newSnapshot("ss-inc2", "ss-inc1", 3*day),
+		newSnapshot("ss-inc3", "ss-inc2", 2*day),
+		newSnapshot("ss-inc4", "ss-inc3", 1*day),
+		newSnapshot("ss-other", "", 40*day),
+	}
+	pruned := selectSnapshotsToPrune(items, 3, 0, now)
+	pruned, protected := keepSnapshotChains(pruned, items)
+	if len(pruned) != 1 || pruned[0].SnapshotId != "ss-other" {
+		t.Error("pruned, got=", pruned, "expected= ss-other")
+	}
+	if len(protected) != 2 {
+		t.Error("the chain of kept incrementals should be protected, got=", protected)
+	}
+
+	//the whole chain is pruned if nothing depends on it
+	pruned, protected = keepSnapshotChains(items[:5], items)
+	if len(pruned) != 5 || len(protected) != 0 {
+		t.Error("whole chain, got=", pruned, protected)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+)
+
+// defaultSshUsers maps the os family of image to its default login user, root for the others.
+var defaultSshUsers = map[string]string{
+	"ubuntu": "ubuntu",
+	"fedora": "fedora",
+	"coreos": "core",
+}
+
+func addSshCmd(root *cobra.Command) {
+	root.AddCommand(newSshCmd())
+}
+
+func newSshCmd() *cobra.Command {
+	param := &sshCmd{
+		instanceCmd: instanceCmd{
+			action: "DescribeInstances",
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "ssh <instance-id|name> [-- command]",
+		Short: "Login to an instance by the system ssh, the address and user are resolved from the instance",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			param.Instance, param.Command = args[0], args[1:]
+			return param.Send()
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return cachedCompletions("instances", fetchInstanceIdCompletions), cobra.ShellCompDirectiveDefault
+		},
+	}
+	param.Build(cmd)
+	return cmd
+}
+
+var _ QingCloudCmd = (*sshCmd)(nil)
+
+// findInstance returns the instance whose id or name is instance, the name must match only one instance.
+func findInstance(instance string) (instanceItem, error) {
+	param := &describeInstanceCmd{
+		instanceCmd: instanceCmd{
+			action: "DescribeInstances",
+		},
+		Verbose: true,
+	}
+	if strings.HasPrefix(instance, "i-") {
+		param.InstanceIds = []string{instance}
+	} else {
+		param.SearchWord = instance
+	}
+	items, err := describeAllInstances(param)
+	if err != nil {
+		return instanceItem{}, err
+	}
+
+	var matched []instanceItem
+	for _, v := range items {
+		//search word matches by substring, so compare the name again
+		if v.InstanceId == instance || v.InstanceName == instance {
+			matched = append(matched, v)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return instanceItem{}, fmt.Errorf("instance %s not found", instance)
+	case 1:
+		return matched[0], nil
+	default:
+		var ids []string
+		for _, v := range matched {
+			ids = append(ids, v.InstanceId)
+		}
+		return instanceItem{}, fmt.Errorf("%d instances named %s, use the instance id instead: %s", len(ids), instance, strings.Join(ids, ", "))
+	}
+}
+
+// sshUser returns the default login user of the os family.
+func sshUser(osFamily string) string {
+	if user, ok := defaultSshUsers[strings.ToLower(osFamily)]; ok {
+		return user
+	}
+	return "root"
+}
+
+// sshIdentity returns the private key path of the first keypair which is configured in ssh_keys, such as
+//
+//	ssh_keys:
+//	  kp-xxxxxxxx: ~/.ssh/id_rsa
+func sshIdentity(keyPairIds []string, keys map[string]string) (string, error) {
+	for _, id := range keyPairIds {
+		if path, ok := keys[id]; ok {
+			return homedir.Expand(path)
+		}
+	}
+	return "", nil
+}
+
+// sshArgs returns the arguments of the system ssh.
+func sshArgs(user, host, identity string, port int64, command []string) []string {
+	var args []string
+	if len(identity) != 0 {
+		args = append(args, "-i", identity)
+	}
+	if port > 0 && port != 22 {
+		args = append(args, "-p", fmt.Sprint(port))
+	}
+	args = append(args, user+"@"+host)
+	if len(command) != 0 {
+		args = append(args, "--")
+		args = append(args, command...)
+	}
+	return args
+}
+
+type sshCmd struct {
+	instanceCmd
+	Instance string
+	Command  []string
+	User     string `name:"user" local:"1" usage:"the login user, default is guessed from the os family of image, such as ubuntu or root"`
+	Identity string `name:"identity" local:"1" usage:"the private key file, default is the key of instance keypair configured in ssh_keys"`
+	Port     int64  `name:"port" local:"1" default:"22" usage:"the ssh port"`
+	Private  bool   `name:"private" local:"1" default:"false" usage:"login by the private ip instead of the eip, such as on a jump host"`
+}
+
+func (sc *sshCmd) Send() error {
+	item, err := findInstance(sc.Instance)
+	if err != nil {
+		return err
+	}
+
+	host := item.Eip.EipAddr
+	if sc.Private {
+		host = item.privateIp()
+	}
+	if len(host) == 0 {
+		if sc.Private {
+			return fmt.Errorf("instance %s has no private ip", item.InstanceId)
+		}
+		return fmt.Errorf("instance %s has no eip, use --private to login by the private ip", item.InstanceId)
+	}
+
+	user := sc.User
+	if len(user) == 0 {
+		user = sshUser(item.Image.OsFamily)
+	}
+	identity := sc.Identity
+	if len(identity) == 0 {
+		if identity, err = sshIdentity(item.KeyPairIds, viper.GetStringMapString("ssh_keys")); err != nil {
+			return err
+		}
+	}
+
+	path, err := exec.LookPath("ssh")
+	if err != nil {
+		return errors.New("ssh is not found in PATH")
+	}
+	c := exec.Command(path, sshArgs(user, host, identity, sc.Port, sc.Command)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		//keep the exit code of the remote command
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+func (sc *sshCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*sc), reflect.ValueOf(*sc), reflect.ValueOf(sc), cmd))
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSshArgs(t *testing.T) {
+	cases := []struct {
+		identity string
+		port     int64
+		command  []string
+		expected string
+	}{
+		{"", 22, nil, "root@1.2.3.4"},
+		{"/home/a/.ssh/id_rsa", 2222, nil, "-i /home/a/.ssh/id_rsa -p 2222 root@1.2.3.4"},
+		{"", 22, []string{"uptime", "-p"}, "root@1.2.3.4 -- uptime -p"},
+	}
+	for _, c := range cases {
+		args := strings.Join(sshArgs("root", "1.2.3.4", c.identity, c.port, c.command), " ")
+		if args != c.expected {
+			t.Errorf("got=%s, expected=%s", args, c.expected)
+		}
+	}
+}
+
+func TestSshUser(t *testing.T) {
+	if user := sshUser("Ubuntu"); user != "ubuntu" {
+		t.Error("ubuntu, got=", user)
+	}
+	if user := sshUser("centos"); user != "root" {
+		t.Error("centos, got=", user)
+	}
+}
+
+func TestSshIdentity(t *testing.T) {
+	keys := map[string]string{"kp-2": "/keys/kp2"}
+	if path, _ := sshIdentity([]string{"kp-1", "kp-2"}, keys); path != "/keys/kp2" {
+		t.Error("configured keypair, got=", path)
+	}
+	if path, _ := sshIdentity([]string{"kp-1"}, keys); path != "" {
+		t.Error("unknown keypair, got=", path)
+	}
+}
@@ -0,0 +1,626 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// stackNamePattern is the valid name of stacks and steps, step names are used as template fields.
+var stackNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// stackActionPattern finds the actions of templates.
+var stackActionPattern = regexp.MustCompile(`{{.*?}}`)
+
+// stackRefPattern finds the steps referred by templates, such as {{.net.id}}.
+var stackRefPattern = regexp.MustCompile(`\.([a-zA-Z_][a-zA-Z0-9_]*)\.[a-zA-Z_]`)
+
+// stackOutputs are the outputs of every step, ids is comma separated.
+var stackOutputs = []string{"id", "ids", "private_key_file"}
+
+func addStackCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "stack",
+		Short: "Create and delete a set of resources in dependency order, such as a test environment",
+	}
+
+	cmd.AddCommand(newCommand("up", "Create the resources of the stack file in dependency order, rollback if any step fails",
+		&stackUpCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+	cmd.AddCommand(newCommand("down", "Delete the resources created by stack up in reverse order",
+		&stackDownCmd{instanceCmd: instanceCmd{action: "DescribeInstances"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*stackUpCmd)(nil)
+var _ QingCloudCmd = (*stackDownCmd)(nil)
+
+// stackKind is a type of step, creating and deleting by the existing commands.
+type stackKind struct {
+	create func() (*instanceCmd, interface{})
+	//idsKey is the field of created ids in the response, an id or a list of ids
+	idsKey string
+	delete func(ids []string) (*instanceCmd, interface{})
+}
+
+var stackKinds = map[string]stackKind{
+	"vxnet": {
+		create: func() (*instanceCmd, interface{}) {
+			p := &createVxnetCmd{instanceCmd: instanceCmd{action: "CreateVxnets"}}
+			return &p.instanceCmd, p
+		},
+		idsKey: "vxnets",
+		delete: func(ids []string) (*instanceCmd, interface{}) {
+			p := &deleteVxnetCmd{instanceCmd: instanceCmd{action: "DeleteVxnets"}, VxnetIds: ids}
+			return &p.instanceCmd, p
+		},
+	},
+	"security_group": {
+		create: func() (*instanceCmd, interface{}) {
+			p := &createSecurityGroupCmd{instanceCmd: instanceCmd{action: "CreateSecurityGroup"}}
+			return &p.instanceCmd, p
+		},
+		idsKey: "security_group_id",
+		delete: func(ids []string) (*instanceCmd, interface{}) {
+			p := &deleteSecurityGroupCmd{instanceCmd: instanceCmd{action: "DeleteSecurityGroups"}, SecurityGroupIds: ids}
+			return &p.instanceCmd, p
+		},
+	},
+	"keypair": {
+		create: func() (*instanceCmd, interface{}) {
+			p := &createKeyPairCmd{instanceCmd: instanceCmd{action: "CreateKeyPair"}}
+			return &p.instanceCmd, p
+		},
+		idsKey: "keypair_id",
+		delete: func(ids []string) (*instanceCmd, interface{}) {
+			p := &deleteKeyPairCmd{instanceCmd: instanceCmd{action: "DeleteKeyPairs"}, KeyPairIds: ids}
+			return &p.instanceCmd, p
+		},
+	},
+	"eip": {
+		create: func() (*instanceCmd, interface{}) {
+			p := &allocateEipCmd{instanceCmd: instanceCmd{action: "AllocateEips"}}
+			return &p.instanceCmd, p
+		},
+		idsKey: "eips",
+		delete: func(ids []string) (*instanceCmd, interface{}) {
+			p := &releaseEipCmd{instanceCmd: instanceCmd{action: "ReleaseEips"}, EipIds: ids}
+			return &p.instanceCmd, p
+		},
+	},
+	"volume": {
+		create: func() (*instanceCmd, interface{}) {
+			p := &createVolumeCmd{instanceCmd: instanceCmd{action: "CreateVolumes"}}
+			return &p.instanceCmd, p
+		},
+		idsKey: "volumes",
+		delete: func(ids []string) (*instanceCmd, interface{}) {
+			p := &deleteVolumeCmd{instanceCmd: instanceCmd{action: "DeleteVolumes"}, VolumeIds: ids}
+			return &p.instanceCmd, p
+		},
+	},
+	"instance": {
+		create: func() (*instanceCmd, interface{}) {
+			p := &runInstanceCmd{instanceCmd: instanceCmd{action: "RunInstances"}}
+			return &p.instanceCmd, p
+		},
+		idsKey: "instances",
+		delete: func(ids []string) (*instanceCmd, interface{}) {
+			p := &terminateInstanceCmd{instanceCmd: instanceCmd{action: "TerminateInstances"}, InstanceIds: ids}
+			return &p.instanceCmd, p
+		},
+	},
+}
+
+// stackFile is the content of stack.yaml. Params of steps are the parameters of the create commands,
+// string values are templates of the outputs of earlier steps, such as {{.net.id}}.
+type stackFile struct {
+	Name  string      `yaml:"name"`
+	Zone  string      `yaml:"zone,omitempty"`
+	Steps []stackStep `yaml:"steps"`
+}
+
+type stackStep struct {
+	Name      string                 `yaml:"name"`
+	Type      string                 `yaml:"type"`
+	DependsOn []string               `yaml:"depends_on,omitempty"`
+	Params    map[string]interface{} `yaml:"params,omitempty"`
+	//Eip is associated to the instance after created, only for instance
+	Eip string `yaml:"eip,omitempty"`
+}
+
+// stackState is the resources created by stack up, saved after every step so stack down can delete them.
+type stackState struct {
+	Name      string          `json:"name"`
+	Zone      string          `json:"zone"`
+	Resources []stackResource `json:"resources"`
+}
+
+type stackResource struct {
+	Step    string            `json:"step"`
+	Type    string            `json:"type"`
+	Ids     []string          `json:"ids"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+func readStackFile(file string) (stackFile, error) {
+	s := stackFile{}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.UnmarshalStrict(data, &s); err != nil {
+		return s, err
+	}
+	if !stackNamePattern.MatchString(s.Name) {
+		return s, fmt.Errorf("invalid stack name %q, must be letters, digits and underscores", s.Name)
+	}
+	return s, nil
+}
+
+// templates returns the template strings of step.
+func (step stackStep) templates() []string {
+	list := []string{step.Eip}
+	for _, v := range step.Params {
+		switch value := v.(type) {
+		case string:
+			list = append(list, value)
+		case []interface{}:
+			for _, elem := range value {
+				list = append(list, fmt.Sprint(elem))
+			}
+		}
+	}
+	return list
+}
+
+// dependencies returns the steps which step depends on, explicitly or by templates.
+func (step stackStep) dependencies() []string {
+	deps := append([]string(nil), step.DependsOn...)
+	for _, s := range step.templates() {
+		for _, action := range stackActionPattern.FindAllString(s, -1) {
+			for _, match := range stackRefPattern.FindAllStringSubmatch(action, -1) {
+				deps = append(deps, match[1])
+			}
+		}
+	}
+	return deps
+}
+
+// orderStackSteps returns the steps sorted by dependencies, the order of file is kept if there is no dependency.
+func orderStackSteps(steps []stackStep) ([]stackStep, error) {
+	index := make(map[string]int)
+	for i, step := range steps {
+		if !stackNamePattern.MatchString(step.Name) {
+			return nil, fmt.Errorf("invalid step name %q, must be letters, digits and underscores", step.Name)
+		}
+		if _, ok := stackKinds[step.Type]; !ok {
+			var kinds []string
+			for k := range stackKinds {
+				kinds = append(kinds, k)
+			}
+			sort.Strings(kinds)
+			return nil, fmt.Errorf("step %s, type is invalid, must be one of %v", step.Name, kinds)
+		}
+		if _, ok := index[step.Name]; ok {
+			return nil, fmt.Errorf("duplicate step name %s", step.Name)
+		}
+		index[step.Name] = i
+	}
+
+	pending := make([]map[string]bool, len(steps))
+	for i, step := range steps {
+		pending[i] = make(map[string]bool)
+		for _, dep := range step.dependencies() {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("step %s depends on unknown step %s", step.Name, dep)
+			}
+			if dep == step.Name {
+				return nil, fmt.Errorf("step %s depends on itself", step.Name)
+			}
+			pending[i][dep] = true
+		}
+	}
+
+	var ordered []stackStep
+	done := make([]bool, len(steps))
+	for len(ordered) != len(steps) {
+		progress := false
+		for i, step := range steps {
+			if done[i] || len(pending[i]) != 0 {
+				continue
+			}
+			done[i], progress = true, true
+			ordered = append(ordered, step)
+			for j := range pending {
+				delete(pending[j], step.Name)
+			}
+			//restart from the beginning, so the earliest ready step goes first
+			break
+		}
+		if !progress {
+			var cyclic []string
+			for i, step := range steps {
+				if !done[i] {
+					cyclic = append(cyclic, step.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle among steps %s", strings.Join(cyclic, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func renderStackTemplate(s string, outputs map[string]map[string]string) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, outputs); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// stackParams renders the params of step with outputs into the values of setFieldsByName.
+func stackParams(step stackStep, outputs map[string]map[string]string) (map[string][]string, error) {
+	values := make(map[string][]string)
+	for name, v := range step.Params {
+		var raw []string
+		switch value := v.(type) {
+		case []interface{}:
+			for _, elem := range value {
+				raw = append(raw, fmt.Sprint(elem))
+			}
+		default:
+			raw = []string{fmt.Sprint(value)}
+		}
+		for _, s := range raw {
+			rendered, err := renderStackTemplate(s, outputs)
+			if err != nil {
+				return nil, fmt.Errorf("step %s, %s, %v", step.Name, name, err)
+			}
+			values[name] = append(values[name], rendered)
+		}
+	}
+	return values, nil
+}
+
+// checkStackParams rejects the local parameters, which are flags of the command but never sent such as tag,
+// and checks the required parameters, so a wrong step fails before the earlier steps create anything.
+func checkStackParams(param interface{}, values map[string][]string) error {
+	typeOf := reflect.TypeOf(param).Elem()
+	for i := 0; i < typeOf.NumField(); i++ {
+		field := typeOf.Field(i)
+		name := field.Tag.Get("name")
+		if len(name) == 0 {
+			continue
+		}
+		_, ok := values[name]
+		if ok && field.Tag.Get("local") == "1" {
+			return fmt.Errorf("%s is not supported in stack", name)
+		}
+		if !ok && field.Tag.Get("required") == "1" {
+			return fmt.Errorf("%s is required", name)
+		}
+	}
+	return nil
+}
+
+// validateStack checks steps before creating anything, templates are rendered with placeholder outputs.
+func validateStack(steps []stackStep) error {
+	placeholder := make(map[string]map[string]string)
+	for _, step := range steps {
+		placeholder[step.Name] = make(map[string]string)
+		for _, key := range stackOutputs {
+			placeholder[step.Name][key] = "placeholder"
+		}
+	}
+	for _, step := range steps {
+		values, err := stackParams(step, placeholder)
+		if err != nil {
+			return err
+		}
+		_, param := stackKinds[step.Type].create()
+		if err := setFieldsByName(param, values); err != nil {
+			return fmt.Errorf("step %s, %v", step.Name, err)
+		}
+		if err := checkStackParams(param, values); err != nil {
+			return fmt.Errorf("step %s, %v", step.Name, err)
+		}
+		if len(step.Eip) != 0 {
+			if step.Type != "instance" {
+				return fmt.Errorf("step %s, eip is only valid for instance", step.Name)
+			}
+			if p := param.(*runInstanceCmd); p.Count > 1 {
+				return fmt.Errorf("step %s, eip requires count 1", step.Name)
+			}
+			if _, err := renderStackTemplate(step.Eip, placeholder); err != nil {
+				return fmt.Errorf("step %s, eip, %v", step.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// stackStatePath returns the state file of the stack named name.
+func stackStatePath(name string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "stacks", name+".json"), nil
+}
+
+func readStackState(name string) (stackState, error) {
+	state := stackState{}
+	path, err := stackStatePath(name)
+	if err != nil {
+		return state, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	return state, json.Unmarshal(data, &state)
+}
+
+// saveStackState writes state, or removes the file if no resource is left.
+func saveStackState(state stackState) error {
+	path, err := stackStatePath(state.Name)
+	if err != nil {
+		return err
+	}
+	if len(state.Resources) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// createdIds decodes the created id or ids of the response field key.
+func createdIds(data []byte, key string) ([]string, error) {
+	resp := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	var ids []string
+	if err := json.Unmarshal(resp[key], &ids); err == nil && len(ids) != 0 {
+		return ids, nil
+	}
+	var id string
+	if err := json.Unmarshal(resp[key], &id); err == nil && len(id) != 0 {
+		return []string{id}, nil
+	}
+	return nil, fmt.Errorf("no %s in the response", key)
+}
+
+// runStackStep creates the resources of step and returns them, the private key of keypair is saved beside the state.
+func runStackStep(stackName string, step stackStep, outputs map[string]map[string]string) (stackResource, error) {
+	res := stackResource{Step: step.Name, Type: step.Type, Outputs: make(map[string]string)}
+	values, err := stackParams(step, outputs)
+	if err != nil {
+		return res, err
+	}
+	kind := stackKinds[step.Type]
+	ic, param := kind.create()
+	if err := setFieldsByName(param, values); err != nil {
+		return res, err
+	}
+
+	data, err := ic.requestData(param)
+	if err != nil {
+		return res, err
+	}
+	resp := struct {
+		apiResponse
+		PrivateKey string `json:"private_key"`
+	}{}
+	if err := decodeResponse(data, &resp); err != nil {
+		return res, err
+	}
+	if res.Ids, err = createdIds(data, kind.idsKey); err != nil {
+		return res, err
+	}
+	res.Outputs["id"], res.Outputs["ids"] = res.Ids[0], strings.Join(res.Ids, ",")
+
+	if len(resp.PrivateKey) != 0 {
+		path, err := stackStatePath(stackName)
+		if err != nil {
+			return res, err
+		}
+		path = strings.TrimSuffix(path, ".json") + "-" + step.Name + ".pem"
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return res, err
+		}
+		if err := ioutil.WriteFile(path, []byte(resp.PrivateKey), 0600); err != nil {
+			return res, err
+		}
+		res.Outputs["private_key_file"] = path
+	}
+	if len(resp.JobId) != 0 {
+		if err := waitJob(resp.JobId); err != nil {
+			return res, err
+		}
+	}
+
+	if len(step.Eip) != 0 {
+		eip, err := renderStackTemplate(step.Eip, outputs)
+		if err != nil {
+			return res, err
+		}
+		p := &associateEipCmd{instanceCmd: instanceCmd{action: "AssociateEip"}, EipId: eip, InstanceId: res.Ids[0]}
+		if err := requestJob(&p.instanceCmd, p, nil); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// deleteStackResources deletes resources in reverse order and returns the ones failed to delete,
+// the deletion goes on after a failure so as much as possible is cleaned up.
+func deleteStackResources(resources []stackResource) ([]stackResource, error) {
+	var failed []stackResource
+	var errs []string
+	for i := len(resources) - 1; i >= 0; i-- {
+		res := resources[i]
+		fmt.Printf("deleting %s %s %s\n", res.Type, res.Step, strings.Join(res.Ids, ","))
+		ic, param := stackKinds[res.Type].delete(res.Ids)
+		if err := requestJob(ic, param, nil); err != nil {
+			fmt.Fprintln(os.Stderr, "failed,", err)
+			errs = append(errs, fmt.Sprintf("%s %s, %v", res.Type, res.Step, err))
+			failed = append([]stackResource{res}, failed...)
+			continue
+		}
+		if path := res.Outputs["private_key_file"]; len(path) != 0 {
+			os.Remove(path)
+		}
+	}
+	if len(errs) != 0 {
+		return failed, errors.New(strings.Join(errs, "; "))
+	}
+	return nil, nil
+}
+
+type stackUpCmd struct {
+	instanceCmd
+	File       string `name:"file" shorthand:"f" local:"1" required:"1" usage:"the stack yaml file, such as stack.yaml"`
+	NoRollback bool   `name:"no-rollback" local:"1" default:"false" usage:"keep the created resources if a step fails, delete them by stack down"`
+}
+
+func (suc *stackUpCmd) Send() error {
+	s, err := readStackFile(suc.File)
+	if err != nil {
+		return err
+	}
+	steps, err := orderStackSteps(s.Steps)
+	if err != nil {
+		return err
+	}
+	if err := validateStack(steps); err != nil {
+		return err
+	}
+	//a state which can not be read may still record resources, it must not be overwritten
+	if _, err := readStackState(s.Name); err == nil {
+		return fmt.Errorf("stack %s is already up, run stack down first", s.Name)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("stack %s, read state failed, %v", s.Name, err)
+	}
+	//zone of stack file is used only if --zone is not specified
+	if len(zone) == 0 && len(s.Zone) != 0 {
+		zone = s.Zone
+	}
+	suc.commonParam()
+
+	state := stackState{Name: s.Name, Zone: suc.zone}
+	outputs := make(map[string]map[string]string)
+	for _, step := range steps {
+		fmt.Printf("creating %s %s\n", step.Type, step.Name)
+		res, err := runStackStep(s.Name, step, outputs)
+		//resources are recorded even if the later part of step failed, such as waiting job
+		if len(res.Ids) != 0 {
+			state.Resources = append(state.Resources, res)
+			if err := saveStackState(state); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("step %s, %v", step.Name, err)
+			if suc.NoRollback {
+				return fmt.Errorf("%v, created resources are kept, delete them by stack down", err)
+			}
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, "rolling back")
+			failed, rollbackErr := deleteStackResources(state.Resources)
+			state.Resources = failed
+			if err := saveStackState(state); err != nil {
+				return err
+			}
+			if rollbackErr != nil {
+				return fmt.Errorf("%v, rollback failed, %v, retry by stack down", err, rollbackErr)
+			}
+			return err
+		}
+		fmt.Printf("created %s %s %s\n", step.Type, step.Name, res.Outputs["ids"])
+		outputs[step.Name] = res.Outputs
+	}
+	fmt.Printf("stack %s is up, %d step(s) created\n", s.Name, len(steps))
+	return nil
+}
+
+func (suc *stackUpCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*suc), reflect.ValueOf(*suc), reflect.ValueOf(suc), cmd))
+}
+
+type stackDownCmd struct {
+	instanceCmd
+	File string `name:"file" shorthand:"f" local:"1" usage:"the stack yaml file, such as stack.yaml"`
+	Name string `name:"name" local:"1" usage:"the stack name, instead of the stack file"`
+	Yes  bool   `name:"yes" local:"1" default:"false" usage:"delete without confirmation"`
+}
+
+func (sdc *stackDownCmd) Send() error {
+	name := sdc.Name
+	if len(sdc.File) != 0 {
+		s, err := readStackFile(sdc.File)
+		if err != nil {
+			return err
+		}
+		name = s.Name
+	}
+	if len(name) == 0 {
+		return errors.New("stack file or name is required")
+	}
+	state, err := readStackState(name)
+	if os.IsNotExist(err) {
+		fmt.Printf("stack %s is not up\n", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	//resources are deleted in the zone they were created
+	zone = state.Zone
+	sdc.commonParam()
+
+	for _, res := range state.Resources {
+		fmt.Printf("%s\t%s\t%s\n", res.Type, res.Step, strings.Join(res.Ids, ","))
+	}
+	if !sdc.Yes && !confirm(fmt.Sprintf("Delete the resources of stack %s above?", name)) {
+		fmt.Println("canceled")
+		return nil
+	}
+	failed, deleteErr := deleteStackResources(state.Resources)
+	state.Resources = failed
+	if err := saveStackState(state); err != nil {
+		return err
+	}
+	if deleteErr != nil {
+		return fmt.Errorf("%v, retry by stack down", deleteErr)
+	}
+	fmt.Printf("stack %s is down\n", name)
+	return nil
+}
+
+func (sdc *stackDownCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*sdc), reflect.ValueOf(*sdc), reflect.ValueOf(sdc), cmd))
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"github.com/mitchellh/go-homedir"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func stackFixture() []stackStep {
+	return []stackStep{
+		{Name: "web", Type: "instance", Eip: "{{.ip.id}}", Params: map[string]interface{}{
+			"image_id":       "img-1",
+			"instance_type":  "c2m4",
+			"vxnets":         []interface{}{"{{.net.id}}"},
+			"security_group": "{{.sg.id}}",
+			"login_mode":     "keypair",
+			"login_keypair":  "{{ .kp.id }}",
+			"volumes":        []interface{}{"{{.data.id}}"},
+		}},
+		{Name: "ip", Type: "eip", Params: map[string]interface{}{"bandwidth": 5}},
+		{Name: "net", Type: "vxnet", Params: map[string]interface{}{"vxnet_name": "test"}},
+		{Name: "sg", Type: "security_group"},
+		{Name: "kp", Type: "keypair", DependsOn: []string{"sg"}},
+		{Name: "data", Type: "volume", Params: map[string]interface{}{"size": 100}},
+	}
+}
+
+func TestOrderStackSteps(t *testing.T) {
+	steps, err := orderStackSteps(stackFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+	if got := strings.Join(names, ","); got != "ip,net,sg,kp,data,web" {
+		t.Error("got=", got)
+	}
+	if err := validateStack(steps); err != nil {
+		t.Error("valid stack, got=", err)
+	}
+
+	cyclic := []stackStep{
+		{Name: "a", Type: "vxnet", DependsOn: []string{"b"}},
+		{Name: "b", Type: "vxnet", Params: map[string]interface{}{"vxnet_name": "{{.a.id}}"}},
+	}
+	if _, err := orderStackSteps(cyclic); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Error("cyclic, got=", err)
+	}
+	if _, err := orderStackSteps([]stackStep{{Name: "a", Type: "router"}}); err == nil {
+		t.Error("should be invalid: router")
+	}
+	if _, err := orderStackSteps([]stackStep{{Name: "a", Type: "vxnet", DependsOn: []string{"b"}}}); err == nil {
+		t.Error("should be invalid: unknown step b")
+	}
+}
+
+func TestValidateStack(t *testing.T) {
+	invalid := [][]stackStep{
+		{{Name: "a", Type: "vxnet", Params: map[string]interface{}{"unknown": "x"}}},
+		{{Name: "a", Type: "volume", Params: map[string]interface{}{"size": "{{.a.size}}"}}},
+		{{Name: "a", Type: "eip", Eip: "eip-1", Params: map[string]interface{}{"bandwidth": 1}}},
+		{{Name: "a", Type: "instance", Eip: "eip-1", Params: map[string]interface{}{"image_id": "img-1", "count": 2}}},
+		{{Name: "a", Type: "instance", Params: map[string]interface{}{"image_id": "img-1", "tag": "web"}}},
+		{{Name: "a", Type: "instance", Params: map[string]interface{}{"instance_type": "c2m4"}}},
+		{{Name: "a", Type: "volume"}},
+	}
+	for i, steps := range invalid {
+		if err := validateStack(steps); err == nil {
+			t.Errorf("%d, expected an error", i)
+		}
+	}
+}
+
+func TestStackParams(t *testing.T) {
+	outputs := map[string]map[string]string{"net": {"id": "vxnet-1", "ids": "vxnet-1"}}
+	values, err := stackParams(stackStep{Name: "web", Params: map[string]interface{}{
+		"vxnets": []interface{}{"{{.net.id}}", "vxnet-0"},
+		"count":  2,
+	}}, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(values["vxnets"], ",") != "vxnet-1,vxnet-0" || values["count"][0] != "2" {
+		t.Error("got=", values)
+	}
+}
+
+func TestCreatedIds(t *testing.T) {
+	ids, err := createdIds([]byte(`{"ret_code":0,"eips":["eip-1","eip-2"]}`), "eips")
+	if err != nil || strings.Join(ids, ",") != "eip-1,eip-2" {
+		t.Error("list, got=", ids, err)
+	}
+	ids, err = createdIds([]byte(`{"ret_code":0,"keypair_id":"kp-1"}`), "keypair_id")
+	if err != nil || len(ids) != 1 || ids[0] != "kp-1" {
+		t.Error("one id, got=", ids, err)
+	}
+	if _, err := createdIds([]byte(`{"ret_code":0}`), "volumes"); err == nil {
+		t.Error("no ids, expected an error")
+	}
+}
+
+func TestStackUpCorruptState(t *testing.T) {
+	home, err := ioutil.TempDir("", "qingcloud-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	file := filepath.Join(home, "stack.yaml")
+	if err := ioutil.WriteFile(file, []byte("name: test\nsteps:\n  - name: net\n    type: vxnet\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path, err := stackStatePath("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("{corrupt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = (&stackUpCmd{File: file}).Send()
+	if err == nil || !strings.Contains(err.Error(), "read state failed") {
+		t.Error("corrupt state, got=", err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "{corrupt" {
+		t.Error("state should not be overwritten, got=", string(data))
+	}
+}
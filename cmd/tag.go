@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+	"strings"
+)
+
+var validResourceType = []string{"instance", "volume", "eip", "security_group", "vxnet", "router", "loadbalancer", "snapshot", "image", "keypair"}
+
+// resourceTypePrefix maps the id prefix of resources to their resource type.
+var resourceTypePrefix = map[string]string{
+	"i-":     "instance",
+	"vol-":   "volume",
+	"eip-":   "eip",
+	"sg-":    "security_group",
+	"vxnet-": "vxnet",
+	"rtr-":   "router",
+	"lb-":    "loadbalancer",
+	"ss-":    "snapshot",
+	"img-":   "image",
+	"kp-":    "keypair",
+}
+
+func addTagCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "tags",
+		Short: "Manage tags, describe, create, delete tag and attach or detach resources",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch tag list, filter by tag id, name etc.",
+		&describeTagCmd{instanceCmd: instanceCmd{action: "DescribeTags"}}))
+	cmd.AddCommand(newCommand("create", "Create a tag",
+		&createTagCmd{instanceCmd: instanceCmd{action: "CreateTag"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many tags which given tag id",
+		&deleteTagCmd{instanceCmd: instanceCmd{action: "DeleteTags"}}))
+	cmd.AddCommand(newCommand("attach", "Attach a tag to one or many resources, such as instance, volume, eip",
+		&attachTagCmd{instanceCmd: instanceCmd{action: "AttachTags"}}))
+	cmd.AddCommand(newCommand("detach", "Detach a tag from one or many resources",
+		&attachTagCmd{instanceCmd: instanceCmd{action: "DetachTags"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeTagCmd)(nil)
+var _ QingCloudCmd = (*createTagCmd)(nil)
+var _ QingCloudCmd = (*deleteTagCmd)(nil)
+var _ QingCloudCmd = (*attachTagCmd)(nil)
+
+// resourceType returns the resource type of id by its prefix, empty if unknown.
+func resourceType(id string) string {
+	for prefix, t := range resourceTypePrefix {
+		if strings.HasPrefix(id, prefix) {
+			return t
+		}
+	}
+	return ""
+}
+
+func describeTags(param *describeTagCmd) ([]tagItem, error) {
+	type response struct {
+		TagSet []tagItem `json:"tag_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.TagSet, nil
+}
+
+// ensureTag returns the id of the tag named name in zone, creates the tag if not exists. Empty zone is the current zone.
+func ensureTag(zone, name string) (string, error) {
+	items, err := describeTags(&describeTagCmd{
+		instanceCmd: instanceCmd{
+			action: "DescribeTags",
+			zone:   zone,
+		},
+		SearchWord: name,
+		Limit:      100,
+	})
+	if err != nil {
+		return "", err
+	}
+	//search word matches by substring, so compare the name again
+	for _, v := range items {
+		if v.TagName == name {
+			return v.TagId, nil
+		}
+	}
+
+	type response struct {
+		TagId string `json:"tag_id"`
+	}
+	param := &createTagCmd{
+		instanceCmd: instanceCmd{
+			action: "CreateTag",
+			zone:   zone,
+		},
+		TagName: name,
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return "", err
+	}
+	return resp.TagId, nil
+}
+
+// attachTags attaches every tag of tagIds to every resource of resourceIds in zone.
+func attachTags(zone string, tagIds, resourceIds []string) error {
+	param := &attachTagCmd{
+		instanceCmd: instanceCmd{
+			action: "AttachTags",
+			zone:   zone,
+		},
+	}
+	for _, tagId := range tagIds {
+		for _, id := range resourceIds {
+			param.Pairs = append(param.Pairs, resourceTagPair{TagId: tagId, ResourceType: resourceType(id), ResourceId: id})
+		}
+	}
+	return param.request(param, nil)
+}
+
+// registerTagIdCompletion completes flagName with the tag ids, described by name.
+func registerTagIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "tags", func() ([]string, error) {
+		var tmp []string
+		items, err := describeTags(&describeTagCmd{
+			instanceCmd: completionInstanceCmd("DescribeTags"),
+			Limit:       100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range items {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s", v.TagId, v.TagName))
+		}
+		return tmp, nil
+	})
+}
+
+type describeTagCmd struct {
+	instanceCmd
+	TagIds     []string `name:"tags" usage:"tag id[s] which want to fetch. Multiple tags set like --tags tag1 --tags tag2"`
+	SearchWord string   `name:"search_word" usage:"search keyword, tag id, name are supported"`
+	Verbose    bool     `name:"verbose" default:"false" usage:"show the resources of tag or not"`
+	Offset     int64    `name:"offset" default:"0" usage:"matched tag offset"`
+	Limit      int64    `name:"limit" default:"20" usage:"matched tag limit, default is 20, max is 100"`
+}
+
+func (dtc *describeTagCmd) Send() error {
+	if dtc.Limit < 20 || dtc.Limit > 100 {
+		dtc.Limit = 20
+	}
+	return dtc.send(dtc)
+}
+
+func (dtc *describeTagCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dtc), reflect.ValueOf(*dtc), reflect.ValueOf(dtc), cmd))
+
+	//for completion
+	registerTagIdCompletion(cmd, "tags")
+}
+
+type createTagCmd struct {
+	instanceCmd
+	TagName string `name:"tag_name" required:"1" usage:"the tag name, such as owner=alice"`
+	Color   string `name:"color" usage:"the tag color, such as #9f9bb7"`
+}
+
+func (ctc *createTagCmd) Send() error {
+	return ctc.send(ctc)
+}
+
+func (ctc *createTagCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*ctc), reflect.ValueOf(*ctc), reflect.ValueOf(ctc), cmd))
+}
+
+type deleteTagCmd struct {
+	instanceCmd
+	TagIds []string `name:"tags" required:"1" usage:"tag id[s] which want to delete. Multiple tags, --tags tag1 --tags tag2"`
+}
+
+func (dtc *deleteTagCmd) Send() error {
+	return dtc.send(dtc)
+}
+
+func (dtc *deleteTagCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dtc), reflect.ValueOf(*dtc), reflect.ValueOf(dtc), cmd))
+
+	//for completion
+	registerTagIdCompletion(cmd, "tags")
+}
+
+// resourceTagPair is one element of the resource_tag_pairs parameter in AttachTags and DetachTags.
+type resourceTagPair struct {
+	TagId        string `name:"tag_id"`
+	ResourceType string `name:"resource_type"`
+	ResourceId   string `name:"resource_id"`
+}
+
+// attachTagCmd is used by both attach and detach, they share the same parameters.
+type attachTagCmd struct {
+	instanceCmd
+	Pairs        []resourceTagPair `name:"resource_tag_pairs"`
+	TagId        string            `name:"tag" local:"1" required:"1" usage:"the tag id"`
+	ResourceIds  []string          `name:"resources" local:"1" required:"1" usage:"resource id[s], such as instance, volume, eip. Multiple resources, --resources i-1 --resources vol-1"`
+	ResourceType string            `name:"resource_type" local:"1" usage:"the resource type, default is guessed from the resource id prefix"`
+}
+
+func (atc *attachTagCmd) Send() error {
+	if len(atc.ResourceType) != 0 && !validParam(validResourceType, atc.ResourceType) {
+		fmt.Println("resource type is invalid, must be one of", validResourceType)
+		os.Exit(0)
+	}
+
+	atc.Pairs = nil
+	for _, id := range atc.ResourceIds {
+		t := atc.ResourceType
+		if len(t) == 0 {
+			t = resourceType(id)
+		}
+		if len(t) == 0 {
+			fmt.Println("unknown resource type of", id, ", please specify --resource_type")
+			os.Exit(0)
+		}
+		atc.Pairs = append(atc.Pairs, resourceTagPair{TagId: atc.TagId, ResourceType: t, ResourceId: id})
+	}
+	return atc.send(atc)
+}
+
+func (atc *attachTagCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*atc), reflect.ValueOf(*atc), reflect.ValueOf(atc), cmd))
+
+	//for completion
+	registerTagIdCompletion(cmd, "tag")
+
+	flagName := "resource_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validResourceType, cobra.ShellCompDirectiveDefault
+	})
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestResourceType(t *testing.T) {
+	cases := map[string]string{
+		"i-abcd1234":     "instance",
+		"vol-abcd1234":   "volume",
+		"eip-abcd1234":   "eip",
+		"sg-abcd1234":    "security_group",
+		"img-abcd1234":   "image",
+		"vxnet-abcd1234": "vxnet",
+		"unknown":        "",
+	}
+	for id, expected := range cases {
+		if got := resourceType(id); got != expected {
+			t.Error(id, "got=", got, "expected=", expected)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// templateNamePattern is the valid name of templates, which is also the file name.
+var templateNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+func addTemplateCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "templates",
+		Short: "Save the flags of run-instances as local launch templates, used by run-instances --template",
+	}
+
+	cmd.AddCommand(newTemplateSaveCmd())
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the saved launch templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listTemplates()
+		},
+	})
+	cmd.AddCommand(newTemplateNameCmd("show <name>", "Print the flags of a launch template", showTemplate))
+	cmd.AddCommand(newTemplateNameCmd("delete <name>", "Delete a launch template", deleteTemplate))
+	root.AddCommand(cmd)
+}
+
+// newTemplateSaveCmd creates templates save, which has the flags of run-instances.
+// Only the flags given are saved, --template saves a new template based on another one,
+// the flags of the base template are saved too unless they are given.
+func newTemplateSaveCmd() *cobra.Command {
+	param := &runInstanceCmd{
+		instanceCmd: instanceCmd{
+			action: "RunInstances",
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "save <name> [run-instances flags]",
+		Short: "Save the given run-instances flags as a launch template, overwrite if exists",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(param.Template) != 0 {
+				if err := applyTemplate(cmd, param.Template); err != nil {
+					return err
+				}
+			}
+			content := make(map[string]interface{})
+			values := make(map[string][]string)
+			//the global flags such as zone are not flags of run-instances
+			cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+				if !f.Changed || f.Name == "template" {
+					return
+				}
+				if slice, ok := f.Value.(pflag.SliceValue); ok {
+					content[f.Name], values[f.Name] = slice.GetSlice(), slice.GetSlice()
+				} else {
+					content[f.Name], values[f.Name] = f.Value.String(), []string{f.Value.String()}
+				}
+			})
+			if len(values) == 0 {
+				return errors.New("no run-instances flag is given")
+			}
+			if err := validateTemplate(values); err != nil {
+				return err
+			}
+			path, err := writeTemplate(args[0], content)
+			if err != nil {
+				return err
+			}
+			fmt.Println("template", args[0], "saved to", path)
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return listTemplateNames(), cobra.ShellCompDirectiveDefault
+		},
+	}
+	param.Build(cmd)
+	//the base template is applied by RunE, before the flags are saved
+	cmd.PreRunE = nil
+	//a template may leave the image to the command line
+	delete(cmd.Flags().Lookup("image_id").Annotations, cobra.BashCompOneRequiredFlag)
+	return cmd
+}
+
+// newTemplateNameCmd creates a command which runs with the template name argument.
+func newTemplateNameCmd(use, short string, run func(name string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(args[0])
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return listTemplateNames(), cobra.ShellCompDirectiveDefault
+		},
+	}
+}
+
+// templateDir returns the directory of launch templates, $HOME/.qingcloud-cli/templates.
+func templateDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+func templatePath(name string) (string, error) {
+	if !templateNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid template name %q, must be letters, digits, dots, dashes and underscores", name)
+	}
+	dir, err := templateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".yaml"), nil
+}
+
+// templateValues converts the decoded template into the values of setFieldsByName.
+func templateValues(content map[string]interface{}) map[string][]string {
+	values := make(map[string][]string)
+	for name, v := range content {
+		if list, ok := v.([]interface{}); ok {
+			for _, elem := range list {
+				values[name] = append(values[name], fmt.Sprint(elem))
+			}
+			continue
+		}
+		values[name] = []string{fmt.Sprint(v)}
+	}
+	return values
+}
+
+// validateTemplate checks the names and values of template against the flags of run-instances.
+func validateTemplate(values map[string][]string) error {
+	if _, ok := values["template"]; ok {
+		return errors.New("template can not refer to another template")
+	}
+	return setFieldsByName(&runInstanceCmd{}, values)
+}
+
+func readTemplate(name string) (map[string][]string, error) {
+	path, err := templatePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	content := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("template %s, %v", name, err)
+	}
+	values := templateValues(content)
+	if err := validateTemplate(values); err != nil {
+		return nil, fmt.Errorf("template %s, %v", name, err)
+	}
+	return values, nil
+}
+
+// writeTemplate saves content as the template named name, returns the file path.
+func writeTemplate(name string, content map[string]interface{}) (string, error) {
+	path, err := templatePath(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	//templates may have login password
+	return path, writeFileAtomic(path, data, 0600)
+}
+
+// listTemplateNames returns the names of saved templates, for completion too.
+func listTemplateNames() []string {
+	dir, err := templateDir()
+	if err != nil {
+		return nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".yaml") {
+			names = append(names, strings.TrimSuffix(f.Name(), ".yaml"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templateSize describes the instance size of template, by instance type or cpu and memory.
+func templateSize(values map[string][]string) string {
+	if v, ok := values["instance_type"]; ok {
+		return v[0]
+	}
+	if len(values["cpu"]) != 0 && len(values["memory"]) != 0 {
+		return fmt.Sprintf("%sC/%sMB", values["cpu"][0], values["memory"][0])
+	}
+	return ""
+}
+
+func listTemplates() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tIMAGE\tSIZE\tFLAGS")
+	for _, name := range listTemplateNames() {
+		values, err := readTemplate(name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t\t\tinvalid, %v\n", name, err)
+			continue
+		}
+		image := ""
+		if v, ok := values["image_id"]; ok {
+			image = v[0]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", name, image, templateSize(values), len(values))
+	}
+	return w.Flush()
+}
+
+func showTemplate(name string) error {
+	values, err := readTemplate(name)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range values[k] {
+			fmt.Printf("--%s %s\n", k, v)
+		}
+	}
+	return nil
+}
+
+func deleteTemplate(name string) error {
+	path, err := templatePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("template %s not found", name)
+		}
+		return err
+	}
+	fmt.Println("template", name, "deleted")
+	return nil
+}
+
+// applyTemplate sets the flags of cmd by the template, the flags given in command line are kept as overrides.
+func applyTemplate(cmd *cobra.Command, name string) error {
+	values, err := readTemplate(name)
+	if err != nil {
+		return err
+	}
+	for flagName, list := range values {
+		if cmd.Flags().Changed(flagName) {
+			continue
+		}
+		for _, v := range list {
+			if err := cmd.Flags().Set(flagName, v); err != nil {
+				return fmt.Errorf("template %s, %s, %v", name, flagName, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestApplyTemplate(t *testing.T) {
+	home, err := ioutil.TempDir("", "qingcloud-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	if _, err := writeTemplate("web", map[string]interface{}{
+		"image_id":      "img-1",
+		"instance_type": "c2m4",
+		"vxnets":        []string{"vxnet-1", "vxnet-2"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if names := listTemplateNames(); len(names) != 1 || names[0] != "web" {
+		t.Fatal("template names, got=", names)
+	}
+
+	param := &runInstanceCmd{}
+	cmd := newCommand("run-instances", "", param)
+	if err := cmd.ParseFlags([]string{"--template", "web", "--instance_type", "c4m8"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.PreRunE(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if param.ImageId != "img-1" || param.InstanceType != "c4m8" || strings.Join(param.Vxnets, ",") != "vxnet-1,vxnet-2" {
+		t.Errorf("got=%+v", param)
+	}
+
+	param = &runInstanceCmd{}
+	cmd = newCommand("run-instances", "", param)
+	if err := cmd.ParseFlags([]string{"--template", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.PreRunE(cmd, nil); err == nil {
+		t.Error("missing template, expected an error")
+	}
+}
+
+func TestSaveTemplate(t *testing.T) {
+	home, err := ioutil.TempDir("", "qingcloud-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	root := &cobra.Command{Use: "qingcloud-cli"}
+	var globalZone string
+	root.PersistentFlags().StringVar(&globalZone, "zone", "", "")
+	addTemplateCmd(root)
+
+	if _, err := writeTemplate("base", map[string]interface{}{"image_id": "img-1", "cpu": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := executeCommand(root, "templates", "save", "web", "--zone", "pek3a", "--template", "base", "--cpu", "2"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := readTemplate("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values["image_id"][0] != "img-1" || values["cpu"][0] != "2" {
+		t.Error("template based on base, got=", values)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	if err := validateTemplate(map[string][]string{"image_id": {"img-1"}, "count": {"2"}}); err != nil {
+		t.Error("valid template, got=", err)
+	}
+	invalid := []map[string][]string{
+		{"unknown": {"1"}},
+		{"count": {"two"}},
+		{"template": {"web"}},
+	}
+	for _, values := range invalid {
+		if err := validateTemplate(values); err == nil {
+			t.Error("expected an error,", values)
+		}
+	}
+	if _, err := templatePath("../web"); err == nil {
+		t.Error("should be invalid: ../web")
+	}
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+)
+
+var validVolumeType = []string{"0", "1", "2", "3", "5", "10", "100", "200"}
+var validVolumeStatus = []string{"pending", "available", "in-use", "suspended", "deleted", "ceased"}
+
+func addVolumeCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "volumes",
+		Short: "Manage volumes, describe, create, attach, detach, resize, modify and delete volume",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch volume list, filter by volume id, type, status etc.",
+		&describeVolumeCmd{instanceCmd: instanceCmd{action: "DescribeVolumes"}}))
+	cmd.AddCommand(newCommand("create", "Create one or many volumes with the same configuration",
+		&createVolumeCmd{instanceCmd: instanceCmd{action: "CreateVolumes"}}))
+	cmd.AddCommand(newCommand("attach", "Attach one or many volumes to an instance",
+		&attachVolumeCmd{instanceCmd: instanceCmd{action: "AttachVolumes"}}))
+	cmd.AddCommand(newCommand("detach", "Detach one or many volumes from an instance",
+		&detachVolumeCmd{instanceCmd: instanceCmd{action: "DetachVolumes"}}))
+	cmd.AddCommand(newCommand("resize", "Expand the size of one or many volumes",
+		&resizeVolumeCmd{instanceCmd: instanceCmd{action: "ResizeVolumes"}}))
+	cmd.AddCommand(newCommand("modify-attributes", "Modify the name and description of a volume",
+		&modifyVolumeAttributesCmd{instanceCmd: instanceCmd{action: "ModifyVolumeAttributes"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many volumes which given volume id",
+		&deleteVolumeCmd{instanceCmd: instanceCmd{action: "DeleteVolumes"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeVolumeCmd)(nil)
+var _ QingCloudCmd = (*createVolumeCmd)(nil)
+var _ QingCloudCmd = (*attachVolumeCmd)(nil)
+var _ QingCloudCmd = (*detachVolumeCmd)(nil)
+var _ QingCloudCmd = (*resizeVolumeCmd)(nil)
+var _ QingCloudCmd = (*modifyVolumeAttributesCmd)(nil)
+var _ QingCloudCmd = (*deleteVolumeCmd)(nil)
+
+// volumeItem is one element of volume_set in DescribeVolumes response.
+type volumeItem struct {
+	VolumeId   string    `json:"volume_id"`
+	VolumeName string    `json:"volume_name"`
+	Size       int64     `json:"size"`
+	VolumeType int64     `json:"volume_type"`
+	Status     string    `json:"status"`
+	Tags       []tagItem `json:"tags"`
+}
+
+func describeVolumes(param *describeVolumeCmd) ([]volumeItem, error) {
+	type response struct {
+		VolumeSet []volumeItem `json:"volume_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.VolumeSet, nil
+}
+
+// describeAllVolumes fetches all volumes matched param page by page.
+func describeAllVolumes(param *describeVolumeCmd) ([]volumeItem, error) {
+	type response struct {
+		VolumeSet  []volumeItem `json:"volume_set"`
+		TotalCount int64        `json:"total_count"`
+	}
+
+	var items []volumeItem
+	for {
+		param.Offset = int64(len(items))
+		param.Limit = 100
+		resp := response{}
+		if err := param.request(param, &resp); err != nil {
+			return nil, err
+		}
+		items = append(items, resp.VolumeSet...)
+		if len(resp.VolumeSet) == 0 || int64(len(items)) >= resp.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+// registerVolumeIdCompletion completes flagName with the volume ids, described by name, size and status.
+func registerVolumeIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "volumes", func() ([]string, error) {
+		var tmp []string
+		items, err := describeVolumes(&describeVolumeCmd{
+			instanceCmd: completionInstanceCmd("DescribeVolumes"),
+			Limit:       100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range items {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s %dGB (%s)", v.VolumeId, v.VolumeName, v.Size, v.Status))
+		}
+		return tmp, nil
+	})
+}
+
+func checkVolumeSize(size int64) {
+	if size < 10 || size%10 != 0 {
+		fmt.Println("volume size is invalid, must be a multiple of 10 and at least 10")
+		os.Exit(0)
+	}
+}
+
+type describeVolumeCmd struct {
+	instanceCmd
+	VolumeIds  []string `name:"volumes" usage:"volume id[s] which want to fetch. Multiple volumes set like --volumes vol1 --volumes vol2"`
+	VolumeType string   `name:"volume_type" usage:"volume type, 0: high performance, 2: high capacity, 3: super high performance, 5: enterprise distributed SAN, 100: basic, 200: SSD enterprise"`
+	Status     []string `name:"status" usage:"volume status[es] which want to fetch. Multiple status --status st1 --status st2"`
+	SearchWord string   `name:"search_word" usage:"search keyword, volume id, name are supported"`
+	Tags       []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Owner      string   `name:"owner" usage:"filter by owner"`
+	Verbose    bool     `name:"verbose" default:"false" usage:"show debug information or not"`
+	Offset     int64    `name:"offset" default:"0" usage:"matched volume offset"`
+	Limit      int64    `name:"limit" default:"20" usage:"matched volume limit, default is 20, max is 100"`
+}
+
+func (dvc *describeVolumeCmd) Send() error {
+	if len(dvc.VolumeType) != 0 && !validParam(validVolumeType, dvc.VolumeType) {
+		fmt.Println("volume type is invalid, must be one of", validVolumeType)
+		os.Exit(0)
+	}
+	if dvc.Limit < 20 || dvc.Limit > 100 {
+		dvc.Limit = 20
+	}
+	return dvc.send(dvc)
+}
+
+func (dvc *describeVolumeCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dvc), reflect.ValueOf(*dvc), reflect.ValueOf(dvc), cmd))
+
+	//for completion
+	registerVolumeIdCompletion(cmd, "volumes")
+
+	flagName := "volume_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validVolumeType, cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "status"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validVolumeStatus, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type createVolumeCmd struct {
+	instanceCmd
+	Size       int64  `name:"size" required:"1" usage:"the volume size, unit GB, must be a multiple of 10"`
+	VolumeName string `name:"volume_name" usage:"the volume name"`
+	VolumeType string `name:"volume_type" usage:"volume type, 0: high performance, 2: high capacity, 3: super high performance, 5: enterprise distributed SAN, 100: basic, 200: SSD enterprise"`
+	Count      int64  `name:"count" usage:"the count of volume you want to create with the same configuration"`
+}
+
+func (cvc *createVolumeCmd) Send() error {
+	checkVolumeSize(cvc.Size)
+	if len(cvc.VolumeType) != 0 && !validParam(validVolumeType, cvc.VolumeType) {
+		fmt.Println("volume type is invalid, must be one of", validVolumeType)
+		os.Exit(0)
+	}
+	if cvc.Count < 1 {
+		cvc.Count = 1
+	}
+	return cvc.send(cvc)
+}
+
+func (cvc *createVolumeCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*cvc), reflect.ValueOf(*cvc), reflect.ValueOf(cvc), cmd))
+
+	//for completion
+	flagName := "volume_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validVolumeType, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type attachVolumeCmd struct {
+	instanceCmd
+	VolumeIds  []string `name:"volumes" required:"1" usage:"volume id[s] which want to attach. Multiple volumes, --volumes vol1 --volumes vol2"`
+	InstanceId string   `name:"instance" required:"1" usage:"the instance id which volumes attach to"`
+}
+
+func (avc *attachVolumeCmd) Send() error {
+	return avc.send(avc)
+}
+
+func (avc *attachVolumeCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*avc), reflect.ValueOf(*avc), reflect.ValueOf(avc), cmd))
+
+	//for completion
+	registerVolumeIdCompletion(cmd, "volumes")
+	registerInstanceIdCompletion(cmd, "instance")
+}
+
+type detachVolumeCmd struct {
+	instanceCmd
+	VolumeIds  []string `name:"volumes" required:"1" usage:"volume id[s] which want to detach. Multiple volumes, --volumes vol1 --volumes vol2"`
+	InstanceId string   `name:"instance" required:"1" usage:"the instance id which volumes detach from"`
+}
+
+func (dvc *detachVolumeCmd) Send() error {
+	return dvc.send(dvc)
+}
+
+func (dvc *detachVolumeCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dvc), reflect.ValueOf(*dvc), reflect.ValueOf(dvc), cmd))
+
+	//for completion
+	registerVolumeIdCompletion(cmd, "volumes")
+	registerInstanceIdCompletion(cmd, "instance")
+}
+
+type resizeVolumeCmd struct {
+	instanceCmd
+	VolumeIds []string `name:"volumes" required:"1" usage:"volume id[s] which want to resize. Multiple volumes, --volumes vol1 --volumes vol2"`
+	Size      int64    `name:"size" required:"1" usage:"the new volume size, unit GB, must be larger than the current size"`
+}
+
+func (rvc *resizeVolumeCmd) Send() error {
+	checkVolumeSize(rvc.Size)
+	return rvc.send(rvc)
+}
+
+func (rvc *resizeVolumeCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*rvc), reflect.ValueOf(*rvc), reflect.ValueOf(rvc), cmd))
+
+	//for completion
+	registerVolumeIdCompletion(cmd, "volumes")
+}
+
+type modifyVolumeAttributesCmd struct {
+	instanceCmd
+	VolumeId    string `name:"volume" required:"1" usage:"the volume id which want to modify"`
+	VolumeName  string `name:"volume_name" usage:"the new volume name"`
+	Description string `name:"description" usage:"the new volume description"`
+}
+
+func (mvc *modifyVolumeAttributesCmd) Send() error {
+	return mvc.send(mvc)
+}
+
+func (mvc *modifyVolumeAttributesCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*mvc), reflect.ValueOf(*mvc), reflect.ValueOf(mvc), cmd))
+
+	//for completion
+	registerVolumeIdCompletion(cmd, "volume")
+}
+
+type deleteVolumeCmd struct {
+	instanceCmd
+	VolumeIds []string `name:"volumes" required:"1" usage:"volume id[s] which want to delete. Multiple volumes, --volumes vol1 --volumes vol2"`
+}
+
+func (dvc *deleteVolumeCmd) Send() error {
+	return dvc.send(dvc)
+}
+
+func (dvc *deleteVolumeCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dvc), reflect.ValueOf(*dvc), reflect.ValueOf(dvc), cmd))
+
+	//for completion
+	registerVolumeIdCompletion(cmd, "volumes")
+}
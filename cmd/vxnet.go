@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+)
+
+var validVxnetType = []string{"0", "1"}
+
+func addVxnetCmd(root *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "vxnets",
+		Short: "Manage private networks, describe, create, delete vxnet and join or leave instances",
+	}
+
+	cmd.AddCommand(newCommand("describe", "Fetch vxnet list, filter by vxnet id, type etc.",
+		&describeVxnetCmd{instanceCmd: instanceCmd{action: "DescribeVxnets"}}))
+	cmd.AddCommand(newCommand("create", "Create one or many vxnets with the same configuration",
+		&createVxnetCmd{instanceCmd: instanceCmd{action: "CreateVxnets"}}))
+	cmd.AddCommand(newCommand("delete", "Delete one or many vxnets which given vxnet id",
+		&deleteVxnetCmd{instanceCmd: instanceCmd{action: "DeleteVxnets"}}))
+	cmd.AddCommand(newCommand("join", "Join one or many instances to a vxnet",
+		&joinVxnetCmd{instanceCmd: instanceCmd{action: "JoinVxnet"}}))
+	cmd.AddCommand(newCommand("leave", "Make one or many instances leave a vxnet",
+		&leaveVxnetCmd{instanceCmd: instanceCmd{action: "LeaveVxnet"}}))
+	root.AddCommand(cmd)
+}
+
+var _ QingCloudCmd = (*describeVxnetCmd)(nil)
+var _ QingCloudCmd = (*createVxnetCmd)(nil)
+var _ QingCloudCmd = (*deleteVxnetCmd)(nil)
+var _ QingCloudCmd = (*joinVxnetCmd)(nil)
+var _ QingCloudCmd = (*leaveVxnetCmd)(nil)
+
+// vxnetItem is one element of vxnet_set in DescribeVxnets response.
+type vxnetItem struct {
+	VxnetId   string `json:"vxnet_id"`
+	VxnetName string `json:"vxnet_name"`
+	VxnetType int64  `json:"vxnet_type"`
+}
+
+func describeVxnets(param *describeVxnetCmd) ([]vxnetItem, error) {
+	type response struct {
+		VxnetSet []vxnetItem `json:"vxnet_set"`
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp.VxnetSet, nil
+}
+
+// registerVxnetIdCompletion completes flagName with the vxnet ids, described by name.
+func registerVxnetIdCompletion(cmd *cobra.Command, flagName string) {
+	registerCachedCompletion(cmd, flagName, "vxnets", func() ([]string, error) {
+		var tmp []string
+		items, err := describeVxnets(&describeVxnetCmd{
+			instanceCmd: completionInstanceCmd("DescribeVxnets"),
+			Limit:       100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range items {
+			tmp = append(tmp, fmt.Sprintf("%s\t%s", v.VxnetId, v.VxnetName))
+		}
+		return tmp, nil
+	})
+}
+
+type describeVxnetCmd struct {
+	instanceCmd
+	VxnetIds   []string `name:"vxnets" usage:"vxnet id[s] which want to fetch. Multiple vxnets set like --vxnets vxnet1 --vxnets vxnet2"`
+	VxnetType  string   `name:"vxnet_type" usage:"vxnet type, 0: unmanaged, 1: managed"`
+	SearchWord string   `name:"search_word" usage:"search keyword, vxnet id, name are supported"`
+	Tags       []string `name:"tags" usage:"filter by bind tag.Multiple tags, --tags tg1 --tags tg2"`
+	Verbose    bool     `name:"verbose" default:"false" usage:"show the instances in vxnet or not"`
+	Offset     int64    `name:"offset" default:"0" usage:"matched vxnet offset"`
+	Limit      int64    `name:"limit" default:"20" usage:"matched vxnet limit, default is 20, max is 100"`
+}
+
+func (dvc *describeVxnetCmd) Send() error {
+	if len(dvc.VxnetType) != 0 && !validParam(validVxnetType, dvc.VxnetType) {
+		fmt.Println("vxnet type is invalid, must be one of", validVxnetType)
+		os.Exit(0)
+	}
+	if dvc.Limit < 20 || dvc.Limit > 100 {
+		dvc.Limit = 20
+	}
+	return dvc.send(dvc)
+}
+
+func (dvc *describeVxnetCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dvc), reflect.ValueOf(*dvc), reflect.ValueOf(dvc), cmd))
+
+	//for completion
+	registerVxnetIdCompletion(cmd, "vxnets")
+
+	flagName := "vxnet_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validVxnetType, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type createVxnetCmd struct {
+	instanceCmd
+	VxnetName string `name:"vxnet_name" usage:"the vxnet name"`
+	VxnetType string `name:"vxnet_type" default:"1" usage:"vxnet type, 0: unmanaged, 1: managed"`
+	Count     int64  `name:"count" usage:"the count of vxnet you want to create with the same configuration"`
+}
+
+func (cvc *createVxnetCmd) Send() error {
+	if !validParam(validVxnetType, cvc.VxnetType) {
+		fmt.Println("vxnet type is invalid, must be one of", validVxnetType)
+		os.Exit(0)
+	}
+	if cvc.Count < 1 {
+		cvc.Count = 1
+	}
+	return cvc.send(cvc)
+}
+
+func (cvc *createVxnetCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*cvc), reflect.ValueOf(*cvc), reflect.ValueOf(cvc), cmd))
+
+	//for completion
+	flagName := "vxnet_type"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validVxnetType, cobra.ShellCompDirectiveDefault
+	})
+}
+
+type deleteVxnetCmd struct {
+	instanceCmd
+	VxnetIds []string `name:"vxnets" required:"1" usage:"vxnet id[s] which want to delete. Multiple vxnets, --vxnets vxnet1 --vxnets vxnet2"`
+}
+
+func (dvc *deleteVxnetCmd) Send() error {
+	return dvc.send(dvc)
+}
+
+func (dvc *deleteVxnetCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dvc), reflect.ValueOf(*dvc), reflect.ValueOf(dvc), cmd))
+
+	//for completion
+	registerVxnetIdCompletion(cmd, "vxnets")
+}
+
+type joinVxnetCmd struct {
+	instanceCmd
+	VxnetId     string   `name:"vxnet" required:"1" usage:"the vxnet id which instances join to"`
+	InstanceIds []string `name:"instances" required:"1" usage:"instance id[s] which want to join. Multiple instances, --instances ins1 --instances ins2"`
+}
+
+func (jvc *joinVxnetCmd) Send() error {
+	return jvc.send(jvc)
+}
+
+func (jvc *joinVxnetCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*jvc), reflect.ValueOf(*jvc), reflect.ValueOf(jvc), cmd))
+
+	//for completion
+	registerVxnetIdCompletion(cmd, "vxnet")
+	registerInstanceIdCompletion(cmd, "instances")
+}
+
+type leaveVxnetCmd struct {
+	instanceCmd
+	VxnetId     string   `name:"vxnet" required:"1" usage:"the vxnet id which instances leave from"`
+	InstanceIds []string `name:"instances" required:"1" usage:"instance id[s] which want to leave. Multiple instances, --instances ins1 --instances ins2"`
+}
+
+func (lvc *leaveVxnetCmd) Send() error {
+	return lvc.send(lvc)
+}
+
+func (lvc *leaveVxnetCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*lvc), reflect.ValueOf(*lvc), reflect.ValueOf(lvc), cmd))
+
+	//for completion
+	registerVxnetIdCompletion(cmd, "vxnet")
+	registerInstanceIdCompletion(cmd, "instances")
+}
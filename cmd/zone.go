@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"reflect"
+	"time"
+)
+
+const zoneCacheName = "zones"
+const zoneCacheTTL = 24 * time.Hour
+const zoneDiscoverTimeout = 5 * time.Second
+
+var validZoneStatus = []string{"active", "faulty", "defunct"}
+
+func addZoneCmd(root *cobra.Command) {
+	root.AddCommand(newCommand("describe-zones", "Fetch the zones which are accessible",
+		&describeZoneCmd{instanceCmd: instanceCmd{action: "DescribeZones", skipZoneCheck: true}}))
+}
+
+var _ QingCloudCmd = (*describeZoneCmd)(nil)
+
+// fetchZones discovers the active zones by DescribeZones.
+func fetchZones() ([]string, error) {
+	type response struct {
+		ZoneSet []struct {
+			ZoneId string `json:"zone_id"`
+			Status string `json:"status"`
+		} `json:"zone_set"`
+	}
+
+	//commonParam exits without credentials, it is not an error of discovery
+	if len(viper.GetString("qy_access_key_id")) == 0 || len(viper.GetString("qy_secret_access_key")) == 0 {
+		return nil, errors.New("no credentials to discover zones")
+	}
+
+	param := &describeZoneCmd{
+		instanceCmd: instanceCmd{
+			action:        "DescribeZones",
+			timeout:       zoneDiscoverTimeout,
+			skipZoneCheck: true,
+		},
+		Status: []string{"active"},
+	}
+	resp := response{}
+	if err := param.request(param, &resp); err != nil {
+		return nil, err
+	}
+	var zones []string
+	for _, v := range resp.ZoneSet {
+		zones = append(zones, v.ZoneId)
+	}
+	return zones, nil
+}
+
+// availableZones returns the discovered zones, cached locally for zoneCacheTTL, and the extra_zones of config.
+// The static validZoneList is used only when neither the api nor the cache is available.
+// If refresh is true, zones are discovered again even if the cache is fresh.
+func availableZones(refresh bool) []string {
+	var zones []string
+	age, err := readCache(zoneCacheName, &zones)
+	if refresh || err != nil || age > zoneCacheTTL {
+		if fetched, err := fetchZones(); err == nil && len(fetched) != 0 {
+			zones = fetched
+			writeCache(zoneCacheName, zones)
+		}
+	}
+	if len(zones) == 0 {
+		zones = validZoneList
+	}
+	return mergeZones(zones, viper.GetStringSlice("extra_zones"))
+}
+
+// validZone checks zone by the available zones, and discovers zones again if zone is not found,
+// so zones added after the cache was written are accepted.
+func validZone(zone string) bool {
+	return validParam(availableZones(false), zone) || validParam(availableZones(true), zone)
+}
+
+func mergeZones(lists ...[]string) []string {
+	var zones []string
+	for _, list := range lists {
+		for _, z := range list {
+			if len(z) != 0 && !validParam(zones, z) {
+				zones = append(zones, z)
+			}
+		}
+	}
+	return zones
+}
+
+type describeZoneCmd struct {
+	instanceCmd
+	Zones  []string `name:"zones" usage:"zone id[s] which want to fetch. Multiple zones set like --zones pek3 --zones sh1a"`
+	Status []string `name:"status" usage:"zone status[es] which want to fetch, active, faulty or defunct"`
+}
+
+func (dzc *describeZoneCmd) Send() error {
+	return dzc.send(dzc)
+}
+
+func (dzc *describeZoneCmd) Build(cmd *cobra.Command) {
+	mustBeOk(buildCobraFlags(reflect.TypeOf(*dzc), reflect.ValueOf(*dzc), reflect.ValueOf(dzc), cmd))
+
+	//for completion
+	flagName := "zones"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return availableZones(false), cobra.ShellCompDirectiveDefault
+	})
+
+	flagName = "status"
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return validZoneStatus, cobra.ShellCompDirectiveDefault
+	})
+}
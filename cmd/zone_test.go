@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestMergeZones(t *testing.T) {
+	zones := mergeZones([]string{"pek3", "sh1a"}, []string{"sh1a", "", "private1"})
+	expected := []string{"pek3", "sh1a", "private1"}
+	if len(zones) != len(expected) {
+		t.Fatal("zones, got=", zones, "expected=", expected)
+	}
+	for i, v := range zones {
+		if v != expected[i] {
+			t.Errorf("zones[%d], got=%s, expected=%s", i, v, expected[i])
+		}
+	}
+}
@@ -5,5 +5,7 @@ go 1.14
 require (
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.1.1
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.1
+	gopkg.in/yaml.v2 v2.2.8
 )